@@ -1,13 +1,23 @@
 package main
 
 import (
+	"bufio"
+	"flag"
+	"fmt"
 	"github.com/qiuhoude/etcd-manage/program"
+	"github.com/qiuhoude/etcd-manage/program/config"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 )
 
 func main() {
+	// 子命令
+	if len(os.Args) > 1 && os.Args[1] == "hash-password" {
+		os.Exit(hashPassword(os.Args[2:]))
+	}
+
 	p, err := program.New()
 	if err != nil {
 		log.Println(err)
@@ -26,3 +36,29 @@ func main() {
 	p.Stop()
 	log.Println("程序退出")
 }
+
+// 生成密码hash,用于填写配置文件中用户的password
+// 用法: etcd-manage hash-password [-algo bcrypt|argon2] [password], 不传password时从标准输入读取一行
+func hashPassword(args []string) int {
+	fs := flag.NewFlagSet("hash-password", flag.ContinueOnError)
+	algo := fs.String("algo", config.HASH_BCRYPT, "hash algorithm: bcrypt or argon2")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	password := fs.Arg(0)
+	if password == "" {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintln(os.Stderr, "password is required")
+			return 2
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	hash, err := config.HashPassword(password, *algo)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println(hash)
+	return 0
+}
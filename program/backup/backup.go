@@ -0,0 +1,228 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"github.com/qiuhoude/etcd-manage/program/common"
+	"github.com/qiuhoude/etcd-manage/program/config"
+	"github.com/qiuhoude/etcd-manage/program/etcdv3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 备份文件扩展名
+const (
+	FILE_EXT     = ".etcdbak.gz"  // key前缀备份
+	SNAPSHOT_EXT = ".snapshot.db" // etcd快照
+	CHECKSUM_EXT = ".sha256"      // 快照旁保存的sha256校验值
+)
+
+var (
+	ErrorFileName = errors.New("invalid backup file name")
+)
+
+// File 备份文件信息
+type File struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // backup 或 snapshot
+	Size     int64  `json:"size"`
+	Created  string `json:"created"`
+	Checksum string `json:"checksum,omitempty"` // 快照的sha256
+}
+
+// Dir 获取etcd服务的备份目录,未配置时为 backups/服务名
+func Dir(s *config.EtcdServer) string {
+	if s.Backup != nil && s.Backup.Dir != "" {
+		return s.Backup.Dir
+	}
+	return filepath.Join(common.GetRootDir(), "backups", s.Name)
+}
+
+// Writer 写入备份目录的临时文件,Commit后才重命名为正式文件
+// 备份目录中不会出现不完整的文件
+type Writer struct {
+	*os.File
+	name string
+}
+
+// Create 创建etcd服务的备份文件,ext 为 FILE_EXT 或 SNAPSHOT_EXT
+func Create(s *config.EtcdServer, ext string) (*Writer, error) {
+	dir := Dir(s)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := ioutil.TempFile(dir, ".tmp-"+s.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{
+		File: f,
+		name: filepath.Join(dir, fmt.Sprintf("%s-%s%s", s.Name, time.Now().Format("20060102150405"), ext)),
+	}, nil
+}
+
+// Commit 写入完成,重命名为正式文件
+func (w *Writer) Commit() (*File, error) {
+	err := w.Sync()
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(w.File.Name(), w.name)
+	}
+	if err != nil {
+		os.Remove(w.File.Name())
+		return nil, err
+	}
+	fi, err := os.Stat(w.name)
+	if err != nil {
+		return nil, err
+	}
+	return newFile(fi), nil
+}
+
+// Abort 放弃写入,删除临时文件
+func (w *Writer) Abort() {
+	w.Close()
+	os.Remove(w.File.Name())
+}
+
+// Run 备份etcd服务key前缀下的所有key到备份目录
+func Run(s *config.EtcdServer) (*File, *etcdv3.BackupHeader, error) {
+	cli, err := etcdv3.GetEtcdCli(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	w, err := Create(s, FILE_EXT)
+	if err != nil {
+		return nil, nil, err
+	}
+	header, err := cli.Backup(w, s.Name, s.KeyPrefix)
+	if err != nil {
+		w.Abort()
+		return nil, nil, err
+	}
+	file, err := w.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, header, nil
+}
+
+// List 列出etcd服务的备份文件,新的在前
+func List(s *config.EtcdServer) ([]*File, error) {
+	fis, err := ioutil.ReadDir(Dir(s))
+	if os.IsNotExist(err) {
+		return []*File{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*File, 0)
+	for _, fi := range fis {
+		if isBackupFile(s, fi, FILE_EXT) {
+			list = append(list, newFile(fi))
+		} else if isBackupFile(s, fi, SNAPSHOT_EXT) {
+			f := newFile(fi)
+			f.Checksum = readChecksum(filepath.Join(Dir(s), fi.Name()+CHECKSUM_EXT))
+			list = append(list, f)
+		}
+	}
+	// 文件名中带有时间,按文件名倒序即为按时间倒序
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name > list[j].Name
+	})
+	return list, nil
+}
+
+// WriteChecksum 在备份目录中的文件旁保存sha256校验值,格式与sha256sum一致
+func WriteChecksum(s *config.EtcdServer, name, checksum string) error {
+	fileName, err := Path(s, name)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName+CHECKSUM_EXT, []byte(checksum+"  "+name+"\n"), 0644)
+}
+
+// 读取校验值文件,不存在时返回空
+func readChecksum(fileName string) string {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// Path 获取备份文件的完整路径,只允许访问备份目录下此服务的备份文件
+func Path(s *config.EtcdServer, name string) (string, error) {
+	if name != filepath.Base(name) || !strings.HasPrefix(name, s.Name+"-") ||
+		!(strings.HasSuffix(name, FILE_EXT) || strings.HasSuffix(name, SNAPSHOT_EXT)) {
+		return "", ErrorFileName
+	}
+	return filepath.Join(Dir(s), name), nil
+}
+
+// Prune 按保留数量和天数删除旧的key前缀备份文件,返回删除的文件名
+// 快照是手动保存的,不会被清理
+func Prune(s *config.EtcdServer) ([]string, error) {
+	if s.Backup == nil || (s.Backup.RetentionCount <= 0 && s.Backup.RetentionDays <= 0) {
+		return nil, nil
+	}
+	fis, err := ioutil.ReadDir(Dir(s))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]os.FileInfo, 0)
+	for _, fi := range fis {
+		if isBackupFile(s, fi, FILE_EXT) {
+			files = append(files, fi)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() > files[j].Name()
+	})
+
+	deadline := time.Now().AddDate(0, 0, -s.Backup.RetentionDays)
+	removed := make([]string, 0)
+	for i, fi := range files {
+		if i == 0 { // 至少保留最新的一个备份
+			continue
+		}
+		overCount := s.Backup.RetentionCount > 0 && i >= s.Backup.RetentionCount
+		overAge := s.Backup.RetentionDays > 0 && fi.ModTime().Before(deadline)
+		if !overCount && !overAge {
+			continue
+		}
+		if err := os.Remove(filepath.Join(Dir(s), fi.Name())); err != nil {
+			return removed, err
+		}
+		removed = append(removed, fi.Name())
+	}
+	return removed, nil
+}
+
+func isBackupFile(s *config.EtcdServer, fi os.FileInfo, ext string) bool {
+	return fi.Mode().IsRegular() && strings.HasPrefix(fi.Name(), s.Name+"-") &&
+		strings.HasSuffix(fi.Name(), ext)
+}
+
+func newFile(fi os.FileInfo) *File {
+	typ := "backup"
+	if strings.HasSuffix(fi.Name(), SNAPSHOT_EXT) {
+		typ = "snapshot"
+	}
+	return &File{
+		Name:    fi.Name(),
+		Type:    typ,
+		Size:    fi.Size(),
+		Created: fi.ModTime().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,43 @@
+package backup
+
+import (
+	"github.com/qiuhoude/etcd-manage/program/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := &config.EtcdServer{Name: "test", Backup: &config.EtcdBackup{Dir: dir}}
+	names := []string{"test-20200101000000" + SNAPSHOT_EXT, "test-20200102000000" + SNAPSHOT_EXT}
+	for _, name := range names {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("snapshot"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := WriteChecksum(s, names[0], "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteChecksum(s, "../"+names[0], "abc"); err != ErrorFileName {
+		t.Fatalf("WriteChecksum() err = %v, want ErrorFileName", err)
+	}
+
+	list, err := List(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("List() len = %d, want 2", len(list))
+	}
+	// 新的在前,没有校验值文件时为空
+	if list[0].Name != names[1] || list[0].Checksum != "" || list[1].Checksum != "abc" {
+		t.Fatalf("List() = %+v, %+v", list[0], list[1])
+	}
+}
@@ -0,0 +1,148 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 解析后的cron表达式
+type Schedule struct {
+	minute, hour, dom, month, dow uint64 // 每一位表示一个允许的值
+	domStar, dowStar              bool   // 日和周是否为 *
+}
+
+// cron每个字段的取值范围
+var cronBounds = []struct{ min, max int }{
+	{0, 59}, // 分
+	{0, 23}, // 时
+	{1, 31}, // 日
+	{1, 12}, // 月
+	{0, 6},  // 周,0为周日
+}
+
+// ParseCron 解析5个字段的cron表达式: 分 时 日 月 周
+// 每个字段支持 * , - / 以及 @daily 等常用别名, 周的7也表示周日
+func ParseCron(spec string) (*Schedule, error) {
+	switch strings.TrimSpace(spec) {
+	case "@yearly", "@annually":
+		spec = "0 0 1 1 *"
+	case "@monthly":
+		spec = "0 0 1 * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	case "@daily", "@midnight":
+		spec = "0 0 * * *"
+	case "@hourly":
+		spec = "0 * * * *"
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errors.New("cron expression must have 5 fields: minute hour day month weekday")
+	}
+	bits := make([]uint64, 5)
+	for i, f := range fields {
+		max := cronBounds[i].max
+		if i == 4 {
+			max = 7
+		}
+		b, err := parseCronField(f, cronBounds[i].min, max)
+		if err != nil {
+			return nil, fmt.Errorf("cron field %q: %v", f, err)
+		}
+		bits[i] = b
+	}
+	if bits[4]&(1<<7) != 0 { // 7 同为周日
+		bits[4] |= 1
+	}
+	return &Schedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}, nil
+}
+
+// 解析一个字段
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, errors.New("invalid step")
+			}
+			part = part[:i]
+		}
+		lo, hi := min, max
+		if part != "*" {
+			if i := strings.Index(part, "-"); i >= 0 {
+				var err1, err2 error
+				lo, err1 = strconv.Atoi(part[:i])
+				hi, err2 = strconv.Atoi(part[i+1:])
+				if err1 != nil || err2 != nil {
+					return 0, errors.New("invalid range")
+				}
+			} else {
+				v, err := strconv.Atoi(part)
+				if err != nil {
+					return 0, errors.New("invalid value")
+				}
+				lo, hi = v, v
+				if step > 1 { // 5/10 表示从5开始每10个
+					hi = max
+				}
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, errors.New("value out of range")
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next 返回t之后下一次执行的时间,精确到分钟,4年内没有匹配时返回零值
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(4, 0, 0)
+	for t.Before(end) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// 日和周都有限制时满足其一即可,与标准cron一致
+func (s *Schedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,33 @@
+package backup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	from := time.Date(2019, 12, 31, 23, 30, 0, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"@daily", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"*/20 * * * *", time.Date(2019, 12, 31, 23, 40, 0, 0, time.UTC)},
+		{"0 3 * * 1-5", time.Date(2020, 1, 1, 3, 0, 0, 0, time.UTC)},
+		{"15 2 29 2 *", time.Date(2020, 2, 29, 2, 15, 0, 0, time.UTC)},
+	}
+	for _, v := range tests {
+		s, err := ParseCron(v.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Next(from); !got.Equal(v.want) {
+			t.Fatalf("ParseCron(%q).Next() = %v, want %v", v.spec, got, v.want)
+		}
+	}
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *"} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Fatalf("ParseCron(%q) want error", spec)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package backup
+
+import (
+	"fmt"
+	"github.com/qiuhoude/etcd-manage/program/config"
+	"github.com/qiuhoude/etcd-manage/program/logger"
+	"sync"
+	"time"
+)
+
+// Scheduler 按配置的cron表达式定时备份etcd服务
+type Scheduler struct {
+	jobs []*job
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+type job struct {
+	server   *config.EtcdServer
+	schedule *Schedule
+}
+
+// NewScheduler 创建定时备份,未配置cron的服务不会备份
+func NewScheduler(servers []*config.EtcdServer) (*Scheduler, error) {
+	s := &Scheduler{
+		jobs: make([]*job, 0),
+		stop: make(chan struct{}),
+	}
+	for _, v := range servers {
+		if v.Backup == nil || v.Backup.Cron == "" {
+			continue
+		}
+		schedule, err := ParseCron(v.Backup.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("server %s backup: %v", v.Name, err)
+		}
+		s.jobs = append(s.jobs, &job{server: v, schedule: schedule})
+	}
+	return s, nil
+}
+
+// Start 启动定时备份
+func (s *Scheduler) Start() {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.run(j)
+	}
+}
+
+// Stop 停止定时备份,等待正在进行的备份完成
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+func (s *Scheduler) run(j *job) {
+	defer s.wg.Done()
+	for {
+		next := j.schedule.Next(time.Now())
+		if next.IsZero() {
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		runJob(j.server)
+	}
+}
+
+// 执行一次备份并清理旧文件,结果记录到操作日志
+func runJob(server *config.EtcdServer) {
+	file, header, err := Run(server)
+	if err != nil {
+		logger.Log.Infow("定时备份失败", "user", "system", "role", "",
+			"server", server.Name, "err", err.Error())
+		return
+	}
+	removed, err := Prune(server)
+	if err != nil {
+		logger.Log.Errorw("清理旧备份错误", "server", server.Name, "err", err)
+	}
+	logger.Log.Infow("定时备份", "user", "system", "role", "",
+		"server", server.Name, "file", file.Name, "size", file.Size,
+		"prefix", header.Prefix, "revision", header.Revision, "removed", removed)
+}
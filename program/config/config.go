@@ -11,11 +11,14 @@ import (
 
 //Config 配置
 type Config struct {
-	Debug   bool          `toml:"debug"`
-	LogPath string        `toml:"log_path"`
-	HTTP    *HTTP         `toml:"http"`
-	Server  []*EtcdServer `toml:"server"`
-	Users   []*User       `toml:"user"`
+	Debug     bool          `toml:"debug"`
+	LogPath   string        `toml:"log_path"`
+	HTTP      *HTTP         `toml:"http"`
+	Server    []*EtcdServer `toml:"server"`
+	Users     []*User       `toml:"user"`
+	Roles     []*Role       `toml:"role"`       // 角色的权限规则
+	UsersFile string        `toml:"users_file"` // 通过接口管理的用户保存的文件,默认为 bin/config/users.toml
+	Session   *Session      `toml:"session"`    // 登录会话配置
 }
 
 // HTTP http件套配置
@@ -34,6 +37,13 @@ type HTTPTls struct {
 	KeyFile  string `toml:"key_file"`
 }
 
+// Session 登录会话配置
+type Session struct {
+	Expire      int `toml:"expire"`       // token有效期(分钟),默认120
+	MaxFailures int `toml:"max_failures"` // 连续登录失败多少次后锁定用户,默认5,小于0为不限制
+	LockTime    int `toml:"lock_time"`    // 锁定时长(分钟),默认15
+}
+
 // EtcdServer etcd 服务
 type EtcdServer struct {
 	Title     string         `toml:"title"`
@@ -46,6 +56,15 @@ type EtcdServer struct {
 	TLSEnable bool           `toml:"tls_enable"` // 是否启用tls连接
 	TLSConfig *EtcdTLSConfig `toml:"tls_config"` // 启用tls时必须配置此内容
 	Roles     []string       `toml:"roles"`      // 可访问此etcd服务的角色列表
+	Backup    *EtcdBackup    `toml:"backup"`     // 定时备份配置
+}
+
+// EtcdBackup 定时备份配置
+type EtcdBackup struct {
+	Cron           string `toml:"cron"`            // cron表达式: 分 时 日 月 周
+	Dir            string `toml:"dir"`             // 备份文件目录,默认为 backups/服务名
+	RetentionCount int    `toml:"retention_count"` // 保留的备份数量,0为不限制
+	RetentionDays  int    `toml:"retention_days"`  // 保留的天数,0为不限制
 }
 
 // EtcdTLSConfig etcd tls配置
@@ -58,8 +77,9 @@ type EtcdTLSConfig struct {
 // User 用户
 type User struct {
 	Username string `toml:"username"`
-	Password string `toml:"password"`
+	Password string `toml:"password"` // 明文或 hash-password 命令生成的bcrypt/argon2 hash
 	Role     string `toml:"role"`
+	stored   bool   // 是否保存在用户文件中
 }
 
 //-------------------------------
@@ -109,6 +129,12 @@ func LoadConfig(cfgPath string) (*Config, error) {
 		}
 		fmt.Printf("%d : v : %v, v.Name : %v\n", i, s, s.Name)
 	}
+	if err := checkRules(cfg.Roles); err != nil {
+		return nil, err
+	}
+	if err := cfg.loadUsers(); err != nil {
+		return nil, err
+	}
 	return cfg, nil
 }
 
@@ -137,6 +163,8 @@ func GetEtcdServer(name string) *EtcdServer {
 
 // GetUserByUsername 根据用户名获取用户信息
 func (c *Config) GetUserByUsername(username string) *User {
+	usersMu.RLock()
+	defer usersMu.RUnlock()
 	if c.Users != nil && len(c.Users) > 0 {
 		for _, u := range c.Users {
 			if u.Username == username {
@@ -1,6 +1,11 @@
 package config
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func TestCheckEtcdServerName(t *testing.T) {
 	tests := []struct {
@@ -17,3 +22,117 @@ func TestCheckEtcdServerName(t *testing.T) {
 		}
 	}
 }
+
+func TestConfigAllow(t *testing.T) {
+	c := &Config{Roles: []*Role{
+		{Name: "dev", Rules: []*Rule{
+			{Server: "default", Prefix: "/app/", Actions: []string{ACTION_READ}},
+			{Server: "default", Prefix: "/app/dev/", Actions: []string{ACTION_READ, ACTION_WRITE, ACTION_DELETE}},
+		}},
+		{Name: "ops", Rules: []*Rule{
+			{Server: "*", Actions: []string{ACTION_READ, ACTION_WRITE, ACTION_DELETE, ACTION_ADMIN}},
+			{Server: "*", Prefix: "/app/prod/", Actions: []string{ACTION_READ}},
+		}},
+	}}
+	tests := []struct {
+		role, server, key, action string
+		want                      bool
+	}{
+		{"dev", "default", "/app/dev/a", ACTION_WRITE, true},
+		{"dev", "default", "/app/prod/a", ACTION_WRITE, false},
+		{"dev", "default", "/app/prod/a", ACTION_READ, true},
+		{"dev", "default", "/other", ACTION_READ, false},
+		{"dev", "test", "/app/dev/a", ACTION_READ, false},
+		{"dev", "default", "", ACTION_ADMIN, false},
+		{"ops", "test", "/app/", ACTION_DELETE, false}, // 目录下有只读的规则
+		{"ops", "test", "/app/dev/", ACTION_DELETE, true},
+		{"ops", "test", "", ACTION_ADMIN, true},
+		{"admin", "default", "", ACTION_ADMIN, true}, // 没有配置规则
+		{"guest", "default", "/app/", ACTION_DELETE, true},
+		{"guest", "default", "", ACTION_ADMIN, false},
+		{"dev", "default", "/app/dev", ACTION_WRITE, true},    // 目录本身
+		{"dev", "default", "/app/devil", ACTION_WRITE, false}, // 按路径匹配
+		{"dev", "default", "/app/devil", ACTION_READ, true},
+		{"ops", "test", "/app/pro", ACTION_DELETE, true}, // 不受 /app/prod/ 限制
+		{"ops", "test", "/app/prod//a", ACTION_WRITE, false},
+	}
+	for _, v := range tests {
+		if got := c.Allow(v.role, v.server, v.key, v.action); got != v.want {
+			t.Fatalf("Allow(%q, %q, %q, %q) = %v, want %v", v.role, v.server, v.key, v.action, got, v.want)
+		}
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	for _, algo := range []string{HASH_BCRYPT, HASH_ARGON2} {
+		hash, err := HashPassword("123456", algo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !CheckPassword(hash, "123456") || CheckPassword(hash, "1234567") {
+			t.Fatalf("CheckPassword() with %s hash %q", algo, hash)
+		}
+	}
+	if !CheckPassword("123456", "123456") || CheckPassword("123456", "") {
+		t.Fatal("CheckPassword() with plaintext")
+	}
+	if _, err := HashPassword("123456", "md5"); err != HashAlgoErr {
+		t.Fatalf("HashPassword() err = %v", err)
+	}
+}
+
+func TestUsersStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &Config{
+		Users:     []*User{{Username: "admin", Password: "123456", Role: "admin"}},
+		UsersFile: filepath.Join(dir, "users.toml"),
+	}
+	if err := c.AddUser("dev", "abc", "dev"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddUser("dev", "abc", "dev"); err != UserExistsErr {
+		t.Fatalf("AddUser() err = %v", err)
+	}
+	if err := c.ResetPassword("admin", "654321"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新读取时用户文件中的用户覆盖配置文件中的同名用户
+	c2 := &Config{
+		Users:     []*User{{Username: "admin", Password: "123456", Role: "admin"}},
+		UsersFile: c.UsersFile,
+	}
+	if err := c2.loadUsers(); err != nil {
+		t.Fatal(err)
+	}
+	if c2.CheckUser("admin", "123456") != nil || c2.CheckUser("admin", "654321") == nil {
+		t.Fatal("CheckUser() admin password not reset")
+	}
+	if u := c2.CheckUser("dev", "abc"); u == nil || u.Role != "dev" {
+		t.Fatalf("CheckUser() dev = %v", u)
+	}
+
+	// 保存失败时修改不生效
+	blocker := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(blocker, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	c2.UsersFile = filepath.Join(blocker, "users.toml")
+	if err := c2.AddUser("test", "abc", "dev"); err == nil {
+		t.Fatal("AddUser() want save error")
+	}
+	if c2.GetUserByUsername("test") != nil {
+		t.Fatal("AddUser() user added after save error")
+	}
+	if err := c2.ResetPassword("admin", "abcdef"); err == nil {
+		t.Fatal("ResetPassword() want save error")
+	}
+	if c2.CheckUser("admin", "654321") == nil {
+		t.Fatal("ResetPassword() password reset after save error")
+	}
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"errors"
+	"strings"
+)
+
+// 操作权限
+const (
+	ACTION_READ   = "read"
+	ACTION_WRITE  = "write"
+	ACTION_DELETE = "delete"
+	ACTION_ADMIN  = "admin" // 集群管理操作,与key无关
+
+	// 没有配置权限规则时拥有admin权限的角色
+	ROLE_ADMIN = "admin"
+)
+
+var ActionErr = errors.New("rule actions can only be read, write, delete or admin")
+
+// Role 角色的权限规则
+type Role struct {
+	Name  string  `toml:"name"`
+	Rules []*Rule `toml:"rule"`
+}
+
+// Rule 权限规则,同一个key匹配多条规则时以前缀最长的为准
+type Rule struct {
+	Server  string   `toml:"server"`  // etcd服务名,为空或*时对所有服务有效
+	Prefix  string   `toml:"prefix"`  // key前缀,按路径匹配 /app/dev 不包括 /app/devil,为空时对所有key有效
+	Actions []string `toml:"actions"` // read,write,delete,admin
+}
+
+// 检查规则中的操作
+func checkRules(roles []*Role) error {
+	for _, r := range roles {
+		for _, rule := range r.Rules {
+			for _, a := range rule.Actions {
+				if a != ACTION_READ && a != ACTION_WRITE && a != ACTION_DELETE && a != ACTION_ADMIN {
+					return ActionErr
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Allow 判断角色是否可以在etcd服务上对key执行操作
+// 没有配置规则的角色兼容以前的行为: admin角色可以执行所有操作,其他角色可以执行除admin外的操作
+// key为目录时其下更具体的规则也必须允许此操作,防止通过操作上级目录绕过限制
+func (c *Config) Allow(role, server, key, action string) bool {
+	var rules []*Rule
+	configured := false
+	for _, r := range c.Roles {
+		if r.Name == role {
+			rules = append(rules, r.Rules...)
+			configured = true
+		}
+	}
+	if !configured {
+		return action != ACTION_ADMIN || role == ROLE_ADMIN
+	}
+
+	key = cleanPath(key)
+	var matched *Rule
+	matchedPrefix := ""
+	for _, rule := range rules {
+		if rule.Server != "" && rule.Server != "*" && rule.Server != server {
+			continue
+		}
+		if action == ACTION_ADMIN { // 管理操作只看服务
+			if rule.allow(action) {
+				return true
+			}
+			continue
+		}
+		prefix := cleanPath(rule.Prefix)
+		if underPath(prefix, key) {
+			if matched == nil || len(prefix) > len(matchedPrefix) {
+				matched, matchedPrefix = rule, prefix
+			}
+		} else if underPath(key, prefix) && !rule.allow(action) {
+			return false
+		}
+	}
+	return matched != nil && matched.allow(action)
+}
+
+// 去掉结尾的/,根目录为/
+func cleanPath(key string) string {
+	if key == "" {
+		return ""
+	}
+	if key = strings.TrimRight(key, "/"); key == "" {
+		return "/"
+	}
+	return key
+}
+
+// key是否在prefix之下或与其相同,按路径判断, prefix为空时包括所有key
+func underPath(prefix, key string) bool {
+	switch prefix {
+	case "":
+		return true
+	case "/":
+		return strings.HasPrefix(key, "/")
+	}
+	return key == prefix || strings.HasPrefix(key, prefix+"/")
+}
+
+func (r *Rule) allow(action string) bool {
+	for _, a := range r.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
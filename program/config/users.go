@@ -0,0 +1,179 @@
+package config
+
+import (
+	"errors"
+	"github.com/pelletier/go-toml"
+	"github.com/qiuhoude/etcd-manage/program/common"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	UserExistsErr   = errors.New("user already exists")
+	UserNotFoundErr = errors.New("user not found")
+	UserInvalidErr  = errors.New("username, password and role can not be empty")
+)
+
+// 用户列表的读写锁,用户可以通过接口修改
+var usersMu sync.RWMutex
+
+// 通过接口管理的用户保存的文件
+type usersStore struct {
+	Users []*User `toml:"user"`
+}
+
+// 获取用户文件路径
+func (c *Config) usersFile() string {
+	if c.UsersFile != "" {
+		return c.UsersFile
+	}
+	return common.GetRootDir() + "bin/config/users.toml"
+}
+
+// 读取用户文件,与配置文件中同名的用户以用户文件为准
+func (c *Config) loadUsers() error {
+	data, err := ioutil.ReadFile(c.usersFile())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	store := new(usersStore)
+	if err := toml.Unmarshal(data, store); err != nil {
+		return err
+	}
+	for _, u := range store.Users {
+		u.stored = true
+		c.setUser(u)
+	}
+	return nil
+}
+
+// 保存通过接口修改过的用户,先写入临时文件再重命名
+func (c *Config) saveUsers(users []*User) error {
+	store := new(usersStore)
+	for _, u := range users {
+		if u.stored {
+			store.Users = append(store.Users, u)
+		}
+	}
+	data, err := toml.Marshal(store)
+	if err != nil {
+		return err
+	}
+	fileName := c.usersFile()
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	tmp := fileName + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fileName)
+}
+
+// 添加或替换同名用户
+func (c *Config) setUser(user *User) {
+	for i, u := range c.Users {
+		if u.Username == user.Username {
+			c.Users[i] = user
+			return
+		}
+	}
+	c.Users = append(c.Users, user)
+}
+
+// CheckUser 校验用户名和密码,成功时返回用户
+func (c *Config) CheckUser(username, password string) *User {
+	u := c.GetUserByUsername(username)
+	if u == nil || !CheckPassword(u.Password, password) {
+		return nil
+	}
+	return u
+}
+
+// ListUsers 获取用户列表
+func (c *Config) ListUsers() []*User {
+	usersMu.RLock()
+	defer usersMu.RUnlock()
+	list := make([]*User, len(c.Users))
+	copy(list, c.Users)
+	return list
+}
+
+// AddUser 添加用户,密码保存为bcrypt hash
+func (c *Config) AddUser(username, password, role string) error {
+	if username == "" || password == "" || role == "" {
+		return UserInvalidErr
+	}
+	hash, err := HashPassword(password, HASH_BCRYPT)
+	if err != nil {
+		return err
+	}
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	for _, u := range c.Users {
+		if u.Username == username {
+			return UserExistsErr
+		}
+	}
+	// 保存成功后才生效
+	users := make([]*User, len(c.Users), len(c.Users)+1)
+	copy(users, c.Users)
+	users = append(users, &User{Username: username, Password: hash, Role: role, stored: true})
+	if err := c.saveUsers(users); err != nil {
+		return err
+	}
+	c.Users = users
+	return nil
+}
+
+// ResetPassword 重置用户密码
+func (c *Config) ResetPassword(username, password string) error {
+	if password == "" {
+		return UserInvalidErr
+	}
+	hash, err := HashPassword(password, HASH_BCRYPT)
+	if err != nil {
+		return err
+	}
+	return c.updateUser(username, func(u *User) {
+		u.Password = hash
+	})
+}
+
+// SetUserRole 修改用户角色
+func (c *Config) SetUserRole(username, role string) error {
+	if role == "" {
+		return UserInvalidErr
+	}
+	return c.updateUser(username, func(u *User) {
+		u.Role = role
+	})
+}
+
+// 修改用户并保存,修改的是副本,正在使用旧用户信息的请求不受影响
+// 保存成功后才生效
+func (c *Config) updateUser(username string, fn func(u *User)) error {
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	for i, u := range c.Users {
+		if u.Username == username {
+			nu := *u
+			fn(&nu)
+			nu.stored = true
+			users := make([]*User, len(c.Users))
+			copy(users, c.Users)
+			users[i] = &nu
+			if err := c.saveUsers(users); err != nil {
+				return err
+			}
+			c.Users = users
+			return nil
+		}
+	}
+	return UserNotFoundErr
+}
@@ -0,0 +1,189 @@
+package etcdv3
+
+import (
+	"context"
+	"github.com/coreos/etcd/clientv3"
+	"strings"
+	"time"
+)
+
+// 权限类型
+var authPermTypes = map[string]clientv3.PermissionType{
+	AUTH_PERM_READ:      clientv3.PermissionType(clientv3.PermRead),
+	AUTH_PERM_WRITE:     clientv3.PermissionType(clientv3.PermWrite),
+	AUTH_PERM_READWRITE: clientv3.PermissionType(clientv3.PermReadWrite),
+}
+
+func authContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 5*time.Second)
+}
+
+// AuthEnable 开启认证,需要先创建root用户
+func (c *Etcd3Client) AuthEnable() error {
+	ctx, cancel := authContext()
+	defer cancel()
+	_, err := c.Client.AuthEnable(ctx)
+	return err
+}
+
+// AuthDisable 关闭认证
+func (c *Etcd3Client) AuthDisable() error {
+	ctx, cancel := authContext()
+	defer cancel()
+	_, err := c.Client.AuthDisable(ctx)
+	return err
+}
+
+// AuthUsers 获取用户列表以及每个用户的角色
+func (c *Etcd3Client) AuthUsers() ([]*AuthUser, error) {
+	ctx, cancel := authContext()
+	defer cancel()
+	resp, err := c.Client.UserList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*AuthUser, 0, len(resp.Users))
+	for _, name := range resp.Users {
+		uresp, err := c.Client.UserGet(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		roles := uresp.Roles
+		if roles == nil {
+			roles = []string{}
+		}
+		users = append(users, &AuthUser{Name: name, Roles: roles})
+	}
+	return users, nil
+}
+
+// AuthUserAdd 创建用户
+func (c *Etcd3Client) AuthUserAdd(name, password string) error {
+	if name == "" || password == "" {
+		return ErrorAuthUser
+	}
+	ctx, cancel := authContext()
+	defer cancel()
+	_, err := c.Client.UserAdd(ctx, name, password)
+	return err
+}
+
+// AuthUserDelete 删除用户
+func (c *Etcd3Client) AuthUserDelete(name string) error {
+	ctx, cancel := authContext()
+	defer cancel()
+	_, err := c.Client.UserDelete(ctx, name)
+	return err
+}
+
+// AuthUserPassword 修改用户密码
+func (c *Etcd3Client) AuthUserPassword(name, password string) error {
+	if name == "" || password == "" {
+		return ErrorAuthUser
+	}
+	ctx, cancel := authContext()
+	defer cancel()
+	_, err := c.Client.UserChangePassword(ctx, name, password)
+	return err
+}
+
+// AuthUserGrantRole 给用户分配角色
+func (c *Etcd3Client) AuthUserGrantRole(name, role string) error {
+	ctx, cancel := authContext()
+	defer cancel()
+	_, err := c.Client.UserGrantRole(ctx, name, role)
+	return err
+}
+
+// AuthUserRevokeRole 收回用户的角色
+func (c *Etcd3Client) AuthUserRevokeRole(name, role string) error {
+	ctx, cancel := authContext()
+	defer cancel()
+	_, err := c.Client.UserRevokeRole(ctx, name, role)
+	return err
+}
+
+// AuthRoles 获取角色列表以及每个角色的权限
+func (c *Etcd3Client) AuthRoles() ([]*AuthRole, error) {
+	ctx, cancel := authContext()
+	defer cancel()
+	resp, err := c.Client.RoleList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]*AuthRole, 0, len(resp.Roles))
+	for _, name := range resp.Roles {
+		rresp, err := c.Client.RoleGet(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		role := &AuthRole{
+			Name:        name,
+			Permissions: make([]*AuthPermission, 0, len(rresp.Perm)),
+		}
+		for _, p := range rresp.Perm {
+			key, rangeEnd := string(p.Key), string(p.RangeEnd)
+			role.Permissions = append(role.Permissions, &AuthPermission{
+				Key:      key,
+				RangeEnd: rangeEnd,
+				Prefix:   rangeEnd != "" && rangeEnd == clientv3.GetPrefixRangeEnd(key),
+				Perm:     strings.ToLower(p.PermType.String()),
+			})
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// AuthRoleAdd 创建角色
+func (c *Etcd3Client) AuthRoleAdd(name string) error {
+	if name == "" {
+		return ErrorAuthRole
+	}
+	ctx, cancel := authContext()
+	defer cancel()
+	_, err := c.Client.RoleAdd(ctx, name)
+	return err
+}
+
+// AuthRoleDelete 删除角色
+func (c *Etcd3Client) AuthRoleDelete(name string) error {
+	ctx, cancel := authContext()
+	defer cancel()
+	_, err := c.Client.RoleDelete(ctx, name)
+	return err
+}
+
+// AuthRoleGrant 给角色授予key范围的权限
+// prefix为true时授予key前缀的权限,否则rangeEnd为空时只授予单个key的权限
+func (c *Etcd3Client) AuthRoleGrant(role string, perm *AuthPermission) error {
+	permType, ok := authPermTypes[perm.Perm]
+	if !ok {
+		return ErrorAuthPerm
+	}
+	if perm.Key == "" {
+		return ErrorAuthKey
+	}
+	ctx, cancel := authContext()
+	defer cancel()
+	_, err := c.Client.RoleGrantPermission(ctx, role, perm.Key, permRangeEnd(perm), permType)
+	return err
+}
+
+// AuthRoleRevoke 收回角色的key范围权限,key和范围必须与授予时一致
+func (c *Etcd3Client) AuthRoleRevoke(role string, perm *AuthPermission) error {
+	if perm.Key == "" {
+		return ErrorAuthKey
+	}
+	ctx, cancel := authContext()
+	defer cancel()
+	_, err := c.Client.RoleRevokePermission(ctx, role, perm.Key, permRangeEnd(perm))
+	return err
+}
+
+func permRangeEnd(perm *AuthPermission) string {
+	if perm.Prefix {
+		return clientv3.GetPrefixRangeEnd(perm.Key)
+	}
+	return perm.RangeEnd
+}
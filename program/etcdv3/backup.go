@@ -0,0 +1,339 @@
+package etcdv3
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 备份文件中文件头之后的每一行,最后一行 End 为true表示备份完整
+type backupLine struct {
+	*BackupKV
+	End   bool  `json:"end,omitempty"`
+	Count int64 `json:"count,omitempty"`
+}
+
+// Backup 将前缀下的所有key以gzip压缩的json行格式写入w
+// 第一行为文件头,之后每行一个key,最后一行为结束标记
+func (c *Etcd3Client) Backup(w io.Writer, server, prefix string) (*BackupHeader, error) {
+	gw := gzip.NewWriter(w)
+	bw := bufio.NewWriter(gw)
+	enc := json.NewEncoder(bw)
+
+	header := &BackupHeader{
+		Format:  BACKUP_FORMAT,
+		Version: BACKUP_VERSION,
+		Server:  server,
+		Prefix:  prefix,
+		Created: time.Now().Format(time.RFC3339),
+	}
+	var count int64
+	leaseTTLs := make(map[int64]int64, 0)
+	err := c.rangeAll(prefix, 0, func(rev int64, kv *mvccpb.KeyValue) error {
+		if header.Revision == 0 { // 第一批数据时写入文件头
+			header.Revision = rev
+			if err := enc.Encode(header); err != nil {
+				return err
+			}
+		}
+		item := &BackupKV{
+			Key:            string(kv.Key),
+			Value:          kv.Value,
+			Version:        kv.Version,
+			CreateRevision: kv.CreateRevision,
+			ModRevision:    kv.ModRevision,
+		}
+		if kv.Lease != 0 {
+			ttl, ok := leaseTTLs[kv.Lease]
+			if !ok {
+				ttl = c.leaseGrantedTTL(kv.Lease)
+				leaseTTLs[kv.Lease] = ttl
+			}
+			if ttl > 0 { // 租约已过期的key马上也会被删除,不需要备份
+				item.Lease = formatLeaseID(kv.Lease)
+				item.LeaseTTL = ttl
+			}
+		}
+		count++
+		return enc.Encode(&backupLine{BackupKV: item})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if header.Revision == 0 { // 没有任何key
+		if err := enc.Encode(header); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Encode(&backupLine{End: true, Count: count}); err != nil {
+		return nil, err
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+	return header, gw.Close()
+}
+
+// ReadBackup 读取备份文件,校验文件头以及结束标记
+// 解压后的大小不能超过 BACKUP_MAX_SIZE, key的数量不能超过 BACKUP_MAX_KEYS
+func ReadBackup(r io.Reader) (*BackupHeader, []*BackupKV, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, ErrorBackupFile
+	}
+	defer gr.Close()
+	lr := &io.LimitedReader{R: gr, N: BACKUP_MAX_SIZE}
+	dec := json.NewDecoder(lr)
+
+	header := new(BackupHeader)
+	if err := dec.Decode(header); err != nil || header.Format != BACKUP_FORMAT {
+		return nil, nil, ErrorBackupFile
+	}
+	if header.Version > BACKUP_VERSION {
+		return nil, nil, fmt.Errorf("unsupported backup version %d", header.Version)
+	}
+	list := make([]*BackupKV, 0)
+	for {
+		line := new(backupLine)
+		if err := dec.Decode(line); err != nil {
+			if lr.N <= 0 {
+				return nil, nil, ErrorBackupSize
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, nil, ErrorBackupEnd
+			}
+			return nil, nil, err
+		}
+		if line.End {
+			if line.Count != int64(len(list)) {
+				return nil, nil, ErrorBackupEnd
+			}
+			return header, list, nil
+		}
+		if line.BackupKV == nil || !underPrefix(header.Prefix, line.Key) {
+			return nil, nil, ErrorBackupFile
+		}
+		if len(list) >= BACKUP_MAX_KEYS {
+			return nil, nil, ErrorBackupSize
+		}
+		list = append(list, line.BackupKV)
+	}
+}
+
+// Restore 将备份中的key写回etcd
+// 每批最多 MAX_TXN_OPS 个key,每个key都校验读取之后没有被修改,某一批失败时撤销已写入的批次
+func (c *Etcd3Client) Restore(header *BackupHeader, list []*BackupKV, opt *RestoreOption) (*RestoreResult, error) {
+	if opt == nil {
+		opt = new(RestoreOption)
+	}
+	switch opt.Conflict {
+	case "":
+		opt.Conflict = CONFLICT_FAIL
+	case CONFLICT_OVERWRITE, CONFLICT_SKIP, CONFLICT_FAIL:
+	default:
+		return nil, ErrorConflict
+	}
+	prefix := header.Prefix
+	if opt.Prefix != "" {
+		prefix = opt.Prefix
+	}
+
+	// 当前的值
+	cur := make(map[string]*mvccpb.KeyValue, 0)
+	err := c.rangeAll(prefix, 0, func(rev int64, kv *mvccpb.KeyValue) error {
+		cur[string(kv.Key)] = kv
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &RestoreResult{
+		Header:    header,
+		Total:     len(list),
+		Conflicts: make([]string, 0),
+		DryRun:    opt.DryRun,
+	}
+	writes := make([]*BackupKV, 0, len(list))
+	leases := make(map[string]int64, 0)
+	for _, item := range list {
+		key := restoreKey(header.Prefix, prefix, item.Key)
+		if old, ok := cur[key]; ok {
+			if string(old.Value) == string(item.Value) {
+				ret.Unchanged++
+				continue
+			}
+			ret.Conflicts = append(ret.Conflicts, key)
+			if opt.Conflict == CONFLICT_SKIP {
+				ret.Skipped++
+				continue
+			}
+			ret.Updated++
+		} else {
+			ret.Created++
+		}
+		writes = append(writes, &BackupKV{
+			Key:      key,
+			Value:    item.Value,
+			Lease:    item.Lease,
+			LeaseTTL: item.LeaseTTL,
+		})
+		if item.Lease != "" {
+			leases[item.Lease] = item.LeaseTTL
+		}
+	}
+	if len(ret.Conflicts) > 0 && opt.Conflict == CONFLICT_FAIL {
+		return ret, ErrorKeyExists
+	}
+	// 父目录在前写入
+	sort.Slice(writes, func(i, j int) bool { return writes[i].Key < writes[j].Key })
+	ret.Batches = (len(writes) + MAX_TXN_OPS - 1) / MAX_TXN_OPS
+	ret.Leases = len(leases)
+	if opt.DryRun || len(writes) == 0 {
+		return ret, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// 重新创建租约,使用原来的授予时间
+	newLeases := make(map[string]clientv3.LeaseID, len(leases))
+	for id, ttl := range leases {
+		resp, err := c.Client.Grant(ctx, ttl)
+		if err != nil {
+			c.revertRestore(nil, cur, newLeases)
+			return nil, err
+		}
+		newLeases[id] = resp.ID
+	}
+
+	for i := 0; i < len(writes); i += MAX_TXN_OPS {
+		batch := writes[i:]
+		if len(batch) > MAX_TXN_OPS {
+			batch = batch[:MAX_TXN_OPS]
+		}
+		cmp := make([]clientv3.Cmp, 0, len(batch))
+		ops := make([]clientv3.Op, 0, len(batch))
+		for _, item := range batch {
+			var modRev int64
+			if old, ok := cur[item.Key]; ok {
+				modRev = old.ModRevision
+			}
+			cmp = append(cmp, clientv3.Compare(clientv3.ModRevision(item.Key), "=", modRev))
+			if item.Lease != "" {
+				ops = append(ops, clientv3.OpPut(item.Key, string(item.Value), clientv3.WithLease(newLeases[item.Lease])))
+			} else {
+				ops = append(ops, clientv3.OpPut(item.Key, string(item.Value)))
+			}
+		}
+		txnResp, err := c.Client.Txn(ctx).If(cmp...).Then(ops...).Commit()
+		if err == nil && !txnResp.Succeeded {
+			err = ErrorKeyModified
+		}
+		if err != nil {
+			c.revertRestore(writes[:i], cur, newLeases)
+			if i == 0 {
+				return nil, err
+			}
+			return nil, ErrorRestoreAbort
+		}
+	}
+	return ret, nil
+}
+
+// 撤销已写入的key,恢复原来的值和租约,并撤销新创建的租约
+func (c *Etcd3Client) revertRestore(writes []*BackupKV, cur map[string]*mvccpb.KeyValue, leases map[string]clientv3.LeaseID) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for i := 0; i < len(writes); i += MAX_TXN_OPS {
+		batch := writes[i:]
+		if len(batch) > MAX_TXN_OPS {
+			batch = batch[:MAX_TXN_OPS]
+		}
+		ops := make([]clientv3.Op, 0, len(batch))
+		for _, item := range batch {
+			old, ok := cur[item.Key]
+			if !ok {
+				ops = append(ops, clientv3.OpDelete(item.Key))
+			} else if old.Lease != 0 {
+				ops = append(ops, clientv3.OpPut(item.Key, string(old.Value), clientv3.WithLease(clientv3.LeaseID(old.Lease))))
+			} else {
+				ops = append(ops, clientv3.OpPut(item.Key, string(old.Value)))
+			}
+		}
+		c.Client.Txn(ctx).Then(ops...).Commit()
+	}
+	for _, id := range leases {
+		c.Client.Revoke(ctx, id)
+	}
+}
+
+// 把from前缀下的key转为to前缀下的key,按路径拼接
+func restoreKey(from, to, key string) string {
+	rel := strings.TrimPrefix(key, strings.TrimSuffix(from, "/"))
+	to = strings.TrimSuffix(to, "/")
+	if to != "" && rel != "" && rel[0] != '/' {
+		rel = "/" + rel
+	}
+	if to+rel == "" {
+		return "/"
+	}
+	return to + rel
+}
+
+// 按key顺序分批读取前缀本身以及其下的所有key,所有批次读取同一个版本
+// rev为0时读取最新版本, fn的rev参数为实际读取的版本
+func (c *Etcd3Client) rangeAll(prefix string, rev int64, fn func(rev int64, kv *mvccpb.KeyValue) error) error {
+	start, end := dirRange(prefix)
+	for {
+		resp, err := c.rangeBatch(start, end, rev)
+		if err != nil {
+			return err
+		}
+		if rev == 0 {
+			rev = resp.Header.Revision
+		}
+		for _, kv := range resp.Kvs {
+			if !underPrefix(prefix, string(kv.Key)) {
+				continue
+			}
+			if err := fn(rev, kv); err != nil {
+				return err
+			}
+		}
+		if !resp.More || len(resp.Kvs) == 0 {
+			return nil
+		}
+		start = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+}
+
+// 每个批次单独设置超时时间
+func (c *Etcd3Client) rangeBatch(start, end string, rev int64) (*clientv3.GetResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return c.Client.Get(ctx, start,
+		clientv3.WithRange(end),
+		clientv3.WithLimit(LIST_SCAN_BATCH),
+		clientv3.WithRev(rev),
+	)
+}
+
+// 租约的授予秒数,租约已过期时为0
+func (c *Etcd3Client) leaseGrantedTTL(id int64) int64 {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := c.Client.TimeToLive(ctx, clientv3.LeaseID(id))
+	if err != nil || resp.TTL <= 0 {
+		return 0
+	}
+	return resp.GrantedTTL
+}
@@ -0,0 +1,92 @@
+package etcdv3
+
+import (
+	"context"
+	"fmt"
+	"github.com/coreos/etcd/clientv3"
+	"sync"
+	"time"
+)
+
+// Consistency 在同一个版本上获取每个节点的HashKV,hash与多数节点不一致的节点为数据不一致
+// 压缩版本不同的节点hash没有可比性,只记录错误不参与比较
+func (c *Etcd3Client) Consistency() (*ConsistencyResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := c.Client.Get(ctx, "/", clientv3.WithCountOnly())
+	if err != nil {
+		return nil, err
+	}
+	mresp, err := c.Client.MemberList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &ConsistencyResult{
+		Revision:   resp.Header.Revision,
+		Consistent: true,
+		Members:    make([]*MemberHash, len(mresp.Members)),
+	}
+	var wg sync.WaitGroup
+	for i, m := range mresp.Members {
+		mh := &MemberHash{
+			MemberID: fmt.Sprintf("%x", m.ID),
+			Name:     m.Name,
+		}
+		ret.Members[i] = mh
+		if len(m.ClientURLs) == 0 {
+			mh.Error = "member has not started"
+			continue
+		}
+		mh.Endpoint = m.ClientURLs[0]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hctx, hcancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer hcancel()
+			hresp, err := c.Client.HashKV(hctx, mh.Endpoint, ret.Revision)
+			if err != nil {
+				mh.Error = err.Error()
+				return
+			}
+			mh.Hash = hresp.Hash
+			mh.CompactRevision = hresp.CompactRevision
+			mh.ok = true
+		}()
+	}
+	wg.Wait()
+
+	// 以节点数最多的 压缩版本+hash 作为基准
+	type hashKey struct {
+		compact int64
+		hash    uint32
+	}
+	counts := make(map[hashKey]int, 0)
+	var ref hashKey
+	for _, mh := range ret.Members {
+		if !mh.ok {
+			continue
+		}
+		k := hashKey{mh.CompactRevision, mh.Hash}
+		counts[k]++
+		if counts[k] > counts[ref] {
+			ref = k
+		}
+	}
+	for _, mh := range ret.Members {
+		if !mh.ok {
+			ret.Consistent = false
+			continue
+		}
+		if mh.CompactRevision != ref.compact {
+			mh.Error = fmt.Sprintf("compact revision %d differs from %d, hash is not comparable", mh.CompactRevision, ref.compact)
+			ret.Consistent = false
+			continue
+		}
+		if mh.Hash != ref.hash {
+			mh.Divergent = true
+			ret.Consistent = false
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,235 @@
+package etcdv3
+
+import (
+	"context"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Copy 复制key或整个目录(包括目录标记key)到新的路径
+func (c *Etcd3Client) Copy(from, to string, opt *CopyOption) (*CopyResult, error) {
+	return c.copyTree(from, to, false, opt)
+}
+
+// Move 移动(重命名)key或整个目录到新的路径
+func (c *Etcd3Client) Move(from, to string, opt *CopyOption) (*CopyResult, error) {
+	return c.copyTree(from, to, true, opt)
+}
+
+// 复制或移动
+// 操作数不超过 MAX_TXN_OPS 时在一个事务中完成,并要求源和目标在读取之后没有被修改
+// 超过时分批写入,每批校验涉及的key没有被修改,全部写入并校验目标值之后才删除源key
+func (c *Etcd3Client) copyTree(from, to string, move bool, opt *CopyOption) (*CopyResult, error) {
+	if opt == nil {
+		opt = new(CopyOption)
+	}
+	switch opt.Conflict {
+	case "":
+		opt.Conflict = CONFLICT_FAIL
+	case CONFLICT_OVERWRITE, CONFLICT_SKIP, CONFLICT_FAIL:
+	default:
+		return nil, ErrorConflict
+	}
+	from = strings.TrimRight(from, "/")
+	to = strings.TrimRight(to, "/")
+	if from == "" || to == "" {
+		return nil, ErrorCopyTarget
+	}
+	if from == to || strings.HasPrefix(to, from+"/") {
+		return nil, ErrorCopyTarget
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// 读取源key,之后的读取都以此时的版本为准
+	srcResp, err := c.Client.Get(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	if len(srcResp.Kvs) == 0 {
+		return nil, ErrorKeyNotFound
+	}
+	rev := srcResp.Header.Revision
+	isDir := isDirKvs(srcResp.Kvs)
+	src := srcResp.Kvs
+	if isDir {
+		resp, err := c.Client.Get(ctx, from+"/", clientv3.WithPrefix(), clientv3.WithRev(rev))
+		if err != nil {
+			return nil, err
+		}
+		src = append(src, resp.Kvs...)
+	}
+
+	// 目标的父目录必须存在
+	if _, parentKey := c.ensureKey(to); parentKey != "" {
+		resp, err := c.Client.Get(ctx, parentKey, clientv3.WithRev(rev))
+		if err != nil {
+			return nil, err
+		}
+		if !isDirKvs(resp.Kvs) {
+			return nil, ErrorCopyParent
+		}
+	}
+
+	// 已存在的目标key
+	dstResp, err := c.Client.Get(ctx, to, clientv3.WithRev(rev))
+	if err != nil {
+		return nil, err
+	}
+	dst := make(map[string]*mvccpb.KeyValue, 0)
+	for _, kv := range dstResp.Kvs {
+		dst[string(kv.Key)] = kv
+	}
+	if isDir {
+		resp, err := c.Client.Get(ctx, to+"/", clientv3.WithPrefix(), clientv3.WithRev(rev))
+		if err != nil {
+			return nil, err
+		}
+		for _, kv := range resp.Kvs {
+			dst[string(kv.Key)] = kv
+		}
+	}
+
+	ret := &CopyResult{
+		Puts:      make([]*CopyItem, 0),
+		Skips:     make([]*CopyItem, 0),
+		Deletes:   make([]string, 0),
+		Conflicts: make([]string, 0),
+		DryRun:    opt.DryRun,
+	}
+	values := make(map[string]string, len(src)) // 目标key -> 值
+	for _, kv := range src {
+		item := &CopyItem{
+			From: string(kv.Key),
+			To:   to + strings.TrimPrefix(string(kv.Key), from),
+		}
+		if old, ok := dst[item.To]; ok {
+			if (string(old.Value) == DEFAULT_DIR_VALUE) != (string(kv.Value) == DEFAULT_DIR_VALUE) {
+				return nil, ErrorKeyType
+			}
+			ret.Conflicts = append(ret.Conflicts, item.To)
+			item.Overwrite = true
+			if opt.Conflict == CONFLICT_SKIP {
+				ret.Skips = append(ret.Skips, item)
+				continue
+			}
+		}
+		values[item.To] = string(kv.Value)
+		ret.Puts = append(ret.Puts, item)
+		if move {
+			ret.Deletes = append(ret.Deletes, item.From)
+		}
+	}
+	if len(ret.Conflicts) > 0 && opt.Conflict == CONFLICT_FAIL {
+		return ret, ErrorKeyExists
+	}
+	// 父目录在前写入,子key在前删除
+	sort.Slice(ret.Puts, func(i, j int) bool { return ret.Puts[i].To < ret.Puts[j].To })
+	sort.Sort(sort.Reverse(sort.StringSlice(ret.Deletes)))
+
+	ret.Atomic = len(ret.Puts)+len(ret.Deletes) <= MAX_TXN_OPS
+	if ret.Atomic {
+		ret.Batches = 1
+	} else {
+		ret.Batches = (len(ret.Puts)+MAX_TXN_OPS-1)/MAX_TXN_OPS + (len(ret.Deletes)+MAX_TXN_OPS-1)/MAX_TXN_OPS
+	}
+	if opt.DryRun || len(ret.Puts) == 0 {
+		return ret, nil
+	}
+
+	if ret.Atomic {
+		ops := make([]clientv3.Op, 0, len(ret.Puts)+len(ret.Deletes))
+		for _, item := range ret.Puts {
+			ops = append(ops, clientv3.OpPut(item.To, values[item.To]))
+		}
+		for _, k := range ret.Deletes {
+			ops = append(ops, clientv3.OpDelete(k))
+		}
+		cmp := []clientv3.Cmp{
+			clientv3.Compare(clientv3.ModRevision(from), "<", rev+1),
+			clientv3.Compare(clientv3.ModRevision(to), "<", rev+1),
+		}
+		if isDir {
+			cmp = append(cmp,
+				clientv3.Compare(clientv3.ModRevision(from+"/"), "<", rev+1).WithPrefix(),
+				clientv3.Compare(clientv3.ModRevision(to+"/"), "<", rev+1).WithPrefix(),
+			)
+		}
+		txnResp, err := c.Client.Txn(ctx).If(cmp...).Then(ops...).Commit()
+		if err != nil {
+			return nil, err
+		}
+		if !txnResp.Succeeded {
+			return nil, ErrorKeyModified
+		}
+		return ret, nil
+	}
+
+	// 分批写入,每批校验目标key仍为读取时的版本
+	srcRevs := make(map[string]int64, len(src))
+	for _, kv := range src {
+		srcRevs[string(kv.Key)] = kv.ModRevision
+	}
+	for i := 0; i < len(ret.Puts); i += MAX_TXN_OPS {
+		batch := ret.Puts[i:]
+		if len(batch) > MAX_TXN_OPS {
+			batch = batch[:MAX_TXN_OPS]
+		}
+		cmp := make([]clientv3.Cmp, 0, len(batch))
+		ops := make([]clientv3.Op, 0, len(batch))
+		for _, item := range batch {
+			var modRev int64
+			if old, ok := dst[item.To]; ok {
+				modRev = old.ModRevision
+			}
+			cmp = append(cmp, clientv3.Compare(clientv3.ModRevision(item.To), "=", modRev))
+			ops = append(ops, clientv3.OpPut(item.To, values[item.To]))
+		}
+		txnResp, err := c.Client.Txn(ctx).If(cmp...).Then(ops...).Commit()
+		if err != nil {
+			return nil, err
+		}
+		if !txnResp.Succeeded {
+			return nil, ErrorKeyModified
+		}
+	}
+	if !move {
+		return ret, nil
+	}
+
+	// 校验写入的值后再删除源key
+	copied, err := c.Client.Get(ctx, to, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	written := kvsToMap(copied.Kvs)
+	for _, item := range ret.Puts {
+		if v, ok := written[item.To]; !ok || v != values[item.To] {
+			return nil, ErrorCopyVerify
+		}
+	}
+	for i := 0; i < len(ret.Deletes); i += MAX_TXN_OPS {
+		batch := ret.Deletes[i:]
+		if len(batch) > MAX_TXN_OPS {
+			batch = batch[:MAX_TXN_OPS]
+		}
+		cmp := make([]clientv3.Cmp, 0, len(batch))
+		ops := make([]clientv3.Op, 0, len(batch))
+		for _, k := range batch {
+			cmp = append(cmp, clientv3.Compare(clientv3.ModRevision(k), "=", srcRevs[k]))
+			ops = append(ops, clientv3.OpDelete(k))
+		}
+		txnResp, err := c.Client.Txn(ctx).If(cmp...).Then(ops...).Commit()
+		if err != nil {
+			return nil, err
+		}
+		if !txnResp.Succeeded {
+			return nil, ErrorKeyModified
+		}
+	}
+	return ret, nil
+}
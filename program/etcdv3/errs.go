@@ -3,7 +3,40 @@ package etcdv3
 import "errors"
 
 var (
-	ErrorPutKey      = errors.New("key is not under a directory or key is a directory or key is not empty")
-	ErrorKeyNotFound = errors.New("key has not been set")
-	ErrorListKey     = errors.New("can only list a directory")
+	ErrorPutKey         = errors.New("key is not under a directory or key is a directory or key is not empty")
+	ErrorKeyNotFound    = errors.New("key has not been set")
+	ErrorListKey        = errors.New("can only list a directory")
+	ErrorFutureRev      = errors.New("required revision is a future revision")
+	ErrorKeyModified    = errors.New("key has been modified, please retry")
+	ErrorTxnTooLarge    = errors.New("too many operations in one transaction")
+	ErrorKeyConflict    = errors.New("key has been modified by others since it was read")
+	ErrorListCursor     = errors.New("invalid list cursor")
+	ErrorListSort       = errors.New("can only sort by key, version or mod_revision")
+	ErrorSearchEmpty    = errors.New("name or value pattern is required")
+	ErrorKeyExists      = errors.New("target key already exists")
+	ErrorKeyType        = errors.New("can not replace a directory with a key or a key with a directory")
+	ErrorCopyTarget     = errors.New("target is the same as or under the source directory")
+	ErrorCopyParent     = errors.New("parent directory of the target does not exist")
+	ErrorCopyVerify     = errors.New("copied values do not match the source, source keys are kept")
+	ErrorConflict       = errors.New("conflict must be overwrite, skip or fail")
+	ErrorImportMode     = errors.New("mode must be merge or replace")
+	ErrorImportKey      = errors.New("document keys can not be empty or contain '/'")
+	ErrorImportDoc      = errors.New("document root must be an object")
+	ErrorImportAbort    = errors.New("import failed and the written batches have been reverted")
+	ErrorBackupFile     = errors.New("not a valid backup file")
+	ErrorBackupEnd      = errors.New("backup file is incomplete")
+	ErrorBackupSize     = errors.New("backup file is too large")
+	ErrorRestoreAbort   = errors.New("restore failed and the written batches have been reverted")
+	ErrorMemberNotFound = errors.New("member not found")
+	ErrorPeerURL        = errors.New("peer urls must be http or https urls with a host")
+	ErrorLearner        = errors.New("learner members require etcd v3.4 or later")
+	ErrorCompactRev     = errors.New("compact revision must be greater than 0")
+	ErrorAlarmType      = errors.New("alarm must be NOSPACE or CORRUPT")
+	ErrorLeaderTarget   = errors.New("target member is not started or not healthy")
+	ErrorAlreadyLeader  = errors.New("target member is already the leader")
+	ErrorNoLeader       = errors.New("cluster has no reachable leader")
+	ErrorAuthUser       = errors.New("user name and password can not be empty")
+	ErrorAuthRole       = errors.New("role name can not be empty")
+	ErrorAuthPerm       = errors.New("perm must be read, write or readwrite")
+	ErrorAuthKey        = errors.New("permission key can not be empty")
 )
@@ -2,9 +2,12 @@ package etcdv3
 
 import (
 	"errors"
+	"fmt"
 	"github.com/coreos/etcd/clientv3"
 	"github.com/coreos/etcd/pkg/transport"
+	"github.com/pelletier/go-toml"
 	"github.com/qiuhoude/etcd-manage/program/config"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
@@ -37,9 +40,24 @@ func NewEtcdCli(etcdCfg *config.EtcdServer) (*Etcd3Client, error) {
 	if len(etcdCfg.Address) == 0 {
 		return nil, errors.New("Etcd connection address cannot be empty")
 	}
+	cliCfg, err := newClientConfig(etcdCfg, etcdCfg.Address)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := clientv3.New(cliCfg)
+	if err != nil {
+		return nil, err
+	}
+	// 保存到map中
+	etcdClis.Store(etcdCfg.Name, cli)
+	return &Etcd3Client{cli}, nil
+}
+
+// 根据服务配置生成连接endpoints的客户端配置
+func newClientConfig(etcdCfg *config.EtcdServer, endpoints []string) (clientv3.Config, error) {
 	// etcd 需要的配置
 	cliCfg := clientv3.Config{
-		Endpoints:   etcdCfg.Address,
+		Endpoints:   endpoints,
 		DialTimeout: 10 * time.Second,
 		Username:    etcdCfg.Username,
 		Password:    etcdCfg.Password,
@@ -53,17 +71,11 @@ func NewEtcdCli(etcdCfg *config.EtcdServer) (*Etcd3Client, error) {
 		}
 		tlsConfig, err := tlsInfo.ClientConfig()
 		if err != nil {
-			return nil, err
+			return cliCfg, err
 		}
 		cliCfg.TLS = tlsConfig
 	}
-	cli, err := clientv3.New(cliCfg)
-	if err != nil {
-		return nil, err
-	}
-	// 保存到map中
-	etcdClis.Store(etcdCfg.Name, cli)
-	return &Etcd3Client{cli}, nil
+	return cliCfg, nil
 }
 
 // GetEtcdCli 获取一个etcd cli对象
@@ -88,21 +100,120 @@ func GetEtcdCli(etcdCfg *config.EtcdServer) (*Etcd3Client, error) {
 
 // node 列表格式化成json
 func NodeJsonFormat(prefix string, list []*Node) (interface{}, error) {
+	return NodeJsonFormatWithOption(prefix, list, nil)
+}
+
+// NodeJsonFormatWithOption node 列表格式化成json, opt为nil时与NodeJsonFormat相同
+func NodeJsonFormatWithOption(prefix string, list []*Node, opt *FormatOption) (interface{}, error) {
 	ret := make(map[string]interface{}, 0)
 	if len(list) == 0 {
 		return ret, nil
 	}
+	valueFn := func(n *Node) interface{} {
+		return formatValue(n.Value)
+	}
+	typed := opt != nil && opt.Typed
+	if typed {
+		valueFn = func(n *Node) interface{} {
+			return typedValue(n.FullDir, n.Value, opt.Hints)
+		}
+	}
 	for _, n := range list {
 		key := strings.TrimPrefix(n.FullDir, prefix)
 		key = strings.TrimRight(key, "/")
 		strs := strings.Split(key, "/")
-		recursiveJsonMap(strs, n, ret)
+		recursiveJsonMap(strs, n, ret, valueFn)
+	}
+	if typed {
+		return indexedToArray(ret), nil
 	}
 	return ret, nil
 }
 
-//递归的将一个值赋值到map中
-func recursiveJsonMap(strs []string, node *Node, parent map[string]interface{}) {
+// NodeTomlFormat node 列表格式化成toml
+func NodeTomlFormat(prefix string, list []*Node) (string, error) {
+	ret, err := NodeJsonFormat(prefix, list)
+	if err != nil {
+		return "", err
+	}
+	v, err := toTomlValue("", ret)
+	if err != nil {
+		return "", err
+	}
+	tree, err := toml.TreeFromMap(v.(map[string]interface{}))
+	if err != nil {
+		return "", err
+	}
+	return tree.ToTomlString()
+}
+
+// 检查并转换为toml可以表示的值, key为当前值的路径,用于错误提示
+func toTomlValue(key string, v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, child := range val {
+			childKey := k
+			if key != "" {
+				childKey = key + "." + k
+			}
+			cv, err := toTomlValue(childKey, child)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = cv
+		}
+		return m, nil
+	case []interface{}:
+		arr := make([]interface{}, 0, len(val))
+		types := make(map[string]bool, 0)
+		for i, child := range val {
+			cv, err := toTomlValue(fmt.Sprintf("%s[%d]", key, i), child)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, cv)
+			types[tomlTypeName(cv)] = true
+		}
+		// 整数和浮点数混合时统一为浮点数
+		if len(types) == 2 && types["int64"] && types["float64"] {
+			for i, cv := range arr {
+				if iv, ok := cv.(int64); ok {
+					arr[i] = float64(iv)
+				}
+			}
+			return arr, nil
+		}
+		// toml 数组中的元素类型必须一致
+		if len(types) > 1 {
+			return nil, fmt.Errorf("toml can not represent array with mixed types at %s", key)
+		}
+		return arr, nil
+	case float64:
+		// 与json显示一致,没有小数部分的数字显示为整数
+		if val == math.Trunc(val) && math.Abs(val) < 1<<53 {
+			return int64(val), nil
+		}
+		return val, nil
+	case nil:
+		return nil, fmt.Errorf("toml can not represent null value at %s", key)
+	}
+	return v, nil
+}
+
+// toml中的类型名,数组中不同元素类型的数组视为同一类型
+func tomlTypeName(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "table"
+	case []interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// 递归的将一个值赋值到map中
+func recursiveJsonMap(strs []string, node *Node, parent map[string]interface{}, valueFn func(*Node) interface{}) {
 	if len(strs) == 0 || strs[0] == "" || node == nil || parent == nil { // 递归结束条件
 		return
 	}
@@ -110,12 +221,12 @@ func recursiveJsonMap(strs []string, node *Node, parent map[string]interface{})
 		if node.Value == DEFAULT_DIR_VALUE {
 			parent[strs[0]] = make(map[string]interface{}, 0)
 		} else {
-			parent[strs[0]] = formatValue(node.Value)
+			parent[strs[0]] = valueFn(node)
 		}
 	}
 
 	if val, ok := parent[strs[0]].(map[string]interface{}); ok {
-		recursiveJsonMap(strs[1:], node, val)
+		recursiveJsonMap(strs[1:], node, val, valueFn)
 	}
 }
 
@@ -0,0 +1,234 @@
+package etcdv3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNodeTomlFormat(t *testing.T) {
+	list := []*Node{
+		{FullDir: "/app/port", Value: "8080"},
+		{FullDir: "/app/debug", Value: "true"},
+		{FullDir: "/app/name", Value: "etcd"},
+		{FullDir: "/app/db", Value: DEFAULT_DIR_VALUE},
+		{FullDir: "/app/db/rate", Value: "0.5"},
+	}
+	ret, err := NodeTomlFormat("/app/", list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"port = 8080", "debug = true", `name = "etcd"`, "[db]", "rate = 0.5"} {
+		if !strings.Contains(ret, want) {
+			t.Fatalf("NodeTomlFormat() = %q, want contains %q", ret, want)
+		}
+	}
+}
+
+func TestToTomlValue(t *testing.T) {
+	tests := []struct {
+		v       interface{}
+		wantErr bool
+	}{
+		{map[string]interface{}{"a": []interface{}{1.0, 1.5}}, false},
+		{map[string]interface{}{"a": []interface{}{1.0, "x"}}, true},
+		{map[string]interface{}{"a": nil}, true},
+	}
+	for _, v := range tests {
+		if _, err := toTomlValue("", v.v); (err != nil) != v.wantErr {
+			t.Fatalf("toTomlValue(%v) err = %v", v.v, err)
+		}
+	}
+}
+
+func TestNodeDotenvFormat(t *testing.T) {
+	list := []*Node{
+		{FullDir: "/app/db", Value: DEFAULT_DIR_VALUE},
+		{FullDir: "/app/db/max-conn", Value: "10"},
+		{FullDir: "/app/db/dsn", Value: "user:pass@tcp(x)/db?a=b"},
+	}
+	ret, err := NodeDotenvFormat("/app/", list, "__")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "DB__DSN=\"user:pass@tcp(x)/db?a=b\"\nDB__MAX_CONN=10\n"
+	if ret != want {
+		t.Fatalf("NodeDotenvFormat() = %q, want %q", ret, want)
+	}
+	ret, err = NodePropertiesFormat("/app/", list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "db.dsn=user:pass@tcp(x)/db?a=b\ndb.max-conn=10\n"
+	if ret != want {
+		t.Fatalf("NodePropertiesFormat() = %q, want %q", ret, want)
+	}
+}
+
+func TestFlattenDocument(t *testing.T) {
+	doc, err := ParseDocument("yaml", []byte("db:\n  port: 3306\n  hosts: [a, b]\nname: app\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kvs, err := FlattenDocument("/app/", doc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"/app/db":         DEFAULT_DIR_VALUE,
+		"/app/db/port":    "3306",
+		"/app/db/hosts":   DEFAULT_DIR_VALUE,
+		"/app/db/hosts/0": "a",
+		"/app/db/hosts/1": "b",
+		"/app/name":       "app",
+	}
+	if len(kvs) != len(want) {
+		t.Fatalf("FlattenDocument() = %v, want %v", kvs, want)
+	}
+	for k, v := range want {
+		if kvs[k] != v {
+			t.Fatalf("FlattenDocument()[%s] = %q, want %q", k, kvs[k], v)
+		}
+	}
+}
+
+func TestTypedRoundTrip(t *testing.T) {
+	list := []*Node{
+		{FullDir: "/app/code", Value: "007"},
+		{FullDir: "/app/version", Value: "1.10"},
+		{FullDir: "/app/rate", Value: "1e3"},
+		{FullDir: "/app/on", Value: "true"},
+		{FullDir: "/app/meta", Value: `{"a":[1,"x"],"b":null}`},
+		{FullDir: "/app/hosts", Value: DEFAULT_DIR_VALUE},
+		{FullDir: "/app/hosts/0", Value: "a"},
+		{FullDir: "/app/hosts/1", Value: "b"},
+	}
+	hints := map[string]string{
+		"/app/":     TYPE_NUMBER,
+		"/app/on":   TYPE_BOOL,
+		"/app/meta": TYPE_JSON,
+	}
+	ret, err := NodeJsonFormatWithOption("/app/", list, &FormatOption{Typed: true, Hints: hints})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(ret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"code":"007","hosts":["a","b"],"meta":{"a":[1,"x"],"b":null},"on":true,"rate":1e3,"version":1.10}`
+	if string(data) != want {
+		t.Fatalf("NodeJsonFormatWithOption() = %s, want %s", data, want)
+	}
+
+	doc, err := ParseDocument("json", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kvs, err := FlattenDocument("/app", doc, hints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kvs) != len(list) {
+		t.Fatalf("FlattenDocument() = %v", kvs)
+	}
+	for _, n := range list {
+		if kvs[n.FullDir] != n.Value {
+			t.Fatalf("FlattenDocument()[%s] = %q, want %q", n.FullDir, kvs[n.FullDir], n.Value)
+		}
+	}
+}
+
+func TestVerifySnapshot(t *testing.T) {
+	db := bytes.Repeat([]byte{7}, 4*SNAPSHOT_PAGE_SIZE)
+	sum := sha256.Sum256(db)
+	snap := append(append([]byte{}, db...), sum[:]...)
+
+	ret, err := VerifySnapshot(bytes.NewReader(snap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ret.HasHash || !ret.Valid || ret.Size != int64(len(snap)) {
+		t.Fatalf("VerifySnapshot() = %+v", ret)
+	}
+
+	snap[10]++ // 数据被修改
+	if ret, _ = VerifySnapshot(bytes.NewReader(snap)); ret.Valid {
+		t.Fatalf("VerifySnapshot() = %+v, want invalid", ret)
+	}
+	if ret, _ = VerifySnapshot(bytes.NewReader(db)); ret.HasHash || ret.Valid {
+		t.Fatalf("VerifySnapshot() = %+v, want no hash", ret)
+	}
+}
+
+func TestReadBackupPrefix(t *testing.T) {
+	backup := func(keys ...string) *bytes.Buffer {
+		buf := new(bytes.Buffer)
+		gw := gzip.NewWriter(buf)
+		enc := json.NewEncoder(gw)
+		enc.Encode(&BackupHeader{Format: BACKUP_FORMAT, Version: BACKUP_VERSION, Prefix: "/app"})
+		for _, k := range keys {
+			enc.Encode(&backupLine{BackupKV: &BackupKV{Key: k}})
+		}
+		enc.Encode(&backupLine{End: true, Count: int64(len(keys))})
+		gw.Close()
+		return buf
+	}
+	if _, list, err := ReadBackup(backup("/app", "/app/x")); err != nil || len(list) != 2 {
+		t.Fatalf("ReadBackup() = %v, %v", list, err)
+	}
+	if _, _, err := ReadBackup(backup("/app/x", "/apple/x")); err != ErrorBackupFile {
+		t.Fatalf("ReadBackup() err = %v, want %v", err, ErrorBackupFile)
+	}
+}
+
+func TestRestoreKey(t *testing.T) {
+	tests := []struct {
+		from, to, key, want string
+	}{
+		{"/app", "/staging/", "/app/x", "/staging/x"},
+		{"/app/", "/staging", "/app/x", "/staging/x"},
+		{"/app", "/staging", "/app", "/staging"},
+		{"/", "/staging/", "/app/x", "/staging/app/x"},
+		{"/", "/", "/app/x", "/app/x"},
+		{"", "", "foo", "foo"},
+	}
+	for _, v := range tests {
+		if got := restoreKey(v.from, v.to, v.key); got != v.want {
+			t.Fatalf("restoreKey(%q, %q, %q) = %q, want %q", v.from, v.to, v.key, got, v.want)
+		}
+	}
+}
+
+func TestDirRange(t *testing.T) {
+	tests := []struct {
+		prefix, key string
+		want        bool
+	}{
+		{"/app", "/app", true},
+		{"/app", "/app/x", true},
+		{"/app", "/apple", false},
+		{"/app", "/app-x", false},
+		{"/app/", "/app", true},
+		{"/app/", "/apple/x", false},
+		{"/", "/apple", true},
+		{"", "apple", true},
+	}
+	for _, v := range tests {
+		start, end := dirRange(v.prefix)
+		inRange := v.key >= start && (end == "\x00" || v.key < end)
+		if v.want && !inRange {
+			t.Fatalf("dirRange(%q) = [%q, %q), want contains %q", v.prefix, start, end, v.key)
+		}
+		if got := underPrefix(v.prefix, v.key); got != v.want {
+			t.Fatalf("underPrefix(%q, %q) = %v, want %v", v.prefix, v.key, got, v.want)
+		}
+	}
+	// /apple 的子key不在 /app 的读取范围内
+	if start, end := dirRange("/app"); "/apple/x" >= start && "/apple/x" < end {
+		t.Fatalf("dirRange(/app) = [%q, %q) contains /apple/x", start, end)
+	}
+}
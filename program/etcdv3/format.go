@@ -0,0 +1,266 @@
+package etcdv3
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// NodeYamlFormat node 列表格式化成yaml
+func NodeYamlFormat(prefix string, list []*Node) (string, error) {
+	ret, err := NodeJsonFormat(prefix, list)
+	if err != nil {
+		return "", err
+	}
+	out, err := yaml.Marshal(ret)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// NodePropertiesFormat node 列表格式化成java properties, 目录层级用 . 连接, 数组下标为 [i]
+func NodePropertiesFormat(prefix string, list []*Node) (string, error) {
+	ret, err := NodeJsonFormat(prefix, list)
+	if err != nil {
+		return "", err
+	}
+	lines := make([]string, 0)
+	flattenValue("", ret, func(path string, i int) string {
+		return fmt.Sprintf("%s[%d]", path, i)
+	}, func(path, k string) string {
+		if path == "" {
+			return k
+		}
+		return path + "." + k
+	}, func(path string, v interface{}) {
+		lines = append(lines, escapeProperties(path, true)+"="+escapeProperties(scalarString(v), false))
+	})
+	sort.Strings(lines)
+	return joinLines(lines), nil
+}
+
+// NodeDotenvFormat node 列表格式化成 .env, key转为大写并用sep连接各级目录
+func NodeDotenvFormat(prefix string, list []*Node, sep string) (string, error) {
+	ret, err := NodeJsonFormat(prefix, list)
+	if err != nil {
+		return "", err
+	}
+	lines := make([]string, 0)
+	join := func(path, k string) string {
+		if path == "" {
+			return envName(k)
+		}
+		return path + sep + envName(k)
+	}
+	flattenValue("", ret, func(path string, i int) string {
+		return join(path, strconv.Itoa(i))
+	}, join, func(path string, v interface{}) {
+		lines = append(lines, path+"="+quoteDotenv(scalarString(v)))
+	})
+	sort.Strings(lines)
+	return joinLines(lines), nil
+}
+
+// 展开为一级的key和值, index和join分别生成数组元素和子节点的路径
+func flattenValue(path string, v interface{},
+	index func(path string, i int) string,
+	join func(path, k string) string,
+	fn func(path string, v interface{})) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			flattenValue(join(path, k), child, index, join, fn)
+		}
+	case []interface{}:
+		for i, child := range val {
+			flattenValue(index(path, i), child, index, join, fn)
+		}
+	default:
+		if path != "" {
+			fn(path, val)
+		}
+	}
+}
+
+// 标量值转为字符串,数字与json显示一致
+func scalarString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// properties 转义, isKey时同时转义空格 = : 等分隔符,非ASCII字符转为 \uXXXX
+func escapeProperties(s string, isKey bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '=', ':', '#', '!':
+			if isKey || i == 0 {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+		case ' ':
+			if isKey || i == 0 {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+		default:
+			if r > 0x7e || r < 0x20 {
+				for _, u := range utf16Units(r) {
+					fmt.Fprintf(&b, `\u%04x`, u)
+				}
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+// 字符转为utf16编码单元
+func utf16Units(r rune) []rune {
+	if r < 0x10000 {
+		return []rune{r}
+	}
+	r -= 0x10000
+	return []rune{0xd800 + (r>>10)&0x3ff, 0xdc00 + r&0x3ff}
+}
+
+// 环境变量名,大写且只包含字母数字和下划线
+func envName(k string) string {
+	var b strings.Builder
+	for _, r := range k {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(unicode.ToUpper(r))
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// .env 的值,包含特殊字符时使用双引号
+func quoteDotenv(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\r\n\"'\\#$=`") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`, "`", "\\`")
+	return `"` + r.Replace(s) + `"`
+}
+
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// 查找key的类型提示,完全匹配优先,其次为最长的前缀
+func typeHint(key string, hints map[string]string) string {
+	if t, ok := hints[key]; ok {
+		return t
+	}
+	hint, longest := "", -1
+	for k, t := range hints {
+		if strings.HasSuffix(k, "/") && strings.HasPrefix(key, k) && len(k) > longest {
+			hint, longest = t, len(k)
+		}
+	}
+	return hint
+}
+
+// 按类型提示转换值,不符合类型的值保持为字符串
+func typedValue(key, v string, hints map[string]string) interface{} {
+	switch typeHint(key, hints) {
+	case TYPE_NUMBER:
+		if isJsonNumber(v) {
+			return json.Number(v)
+		}
+	case TYPE_INT:
+		if _, err := strconv.ParseInt(v, 10, 64); err == nil && isJsonNumber(v) {
+			return json.Number(v)
+		}
+	case TYPE_BOOL:
+		if v == "true" || v == "false" {
+			return v == "true"
+		}
+	case TYPE_JSON:
+		// 只有与重新编码后一致的json才内嵌,保证导入后的值不变
+		if canon, err := canonicalJson(v); err == nil && canon == v {
+			return json.RawMessage(v)
+		}
+	}
+	return v
+}
+
+// 是否为json数字的原样写法,如 007 不是
+func isJsonNumber(v string) bool {
+	if v == "" || !(v[0] == '-' || (v[0] >= '0' && v[0] <= '9')) {
+		return false
+	}
+	var n json.Number
+	return json.Unmarshal([]byte(v), &n) == nil
+}
+
+// json重新编码后的字符串,对象的key排序并去掉空白
+func canonicalJson(v string) (string, error) {
+	var doc interface{}
+	dec := json.NewDecoder(strings.NewReader(v))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return "", err
+	}
+	if dec.More() {
+		return "", errors.New("invalid json")
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// 将key为从0开始连续数字的map转为数组
+func indexedToArray(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	for k, child := range m {
+		m[k] = indexedToArray(child)
+	}
+	if len(m) == 0 {
+		return m
+	}
+	arr := make([]interface{}, len(m))
+	for i := range arr {
+		child, ok := m[strconv.Itoa(i)]
+		if !ok {
+			return m
+		}
+		arr[i] = child
+	}
+	return arr
+}
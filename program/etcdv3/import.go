@@ -0,0 +1,284 @@
+package etcdv3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v2"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDocument 解析json,yaml或toml文档
+func ParseDocument(format string, data []byte) (map[string]interface{}, error) {
+	switch format {
+	case "json":
+		var doc interface{}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber() // 保留数字原样
+		if err := dec.Decode(&doc); err != nil {
+			return nil, err
+		}
+		m, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil, ErrorImportDoc
+		}
+		return m, nil
+	case "yaml":
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		m, ok := yamlToMap(doc).(map[string]interface{})
+		if !ok {
+			return nil, ErrorImportDoc
+		}
+		return m, nil
+	case "toml":
+		tree, err := toml.LoadBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		return tree.ToMap(), nil
+	}
+	return nil, fmt.Errorf("unsupported document format: %s", format)
+}
+
+// yaml解析出的map的key为interface{},转为string
+func yamlToMap(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, child := range val {
+			m[fmt.Sprint(k)] = yamlToMap(child)
+		}
+		return m
+	case []interface{}:
+		for i, child := range val {
+			val[i] = yamlToMap(child)
+		}
+	}
+	return v
+}
+
+// FlattenDocument 将文档展开为前缀下的key,对象为目录,数组为以下标命名的目录
+// hints 中类型为 TYPE_JSON 的key不展开,整体保存为json字符串
+// 返回 key->值, 目录的值为 DEFAULT_DIR_VALUE
+func FlattenDocument(prefix string, doc map[string]interface{}, hints map[string]string) (map[string]string, error) {
+	prefix = strings.TrimRight(prefix, "/")
+	kvs := make(map[string]string, 0)
+	if err := flattenDocument(prefix, doc, kvs, hints); err != nil {
+		return nil, err
+	}
+	return kvs, nil
+}
+
+func flattenDocument(dir string, v interface{}, kvs map[string]string, hints map[string]string) error {
+	children := make(map[string]interface{}, 0)
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			if k == "" || strings.Contains(k, "/") {
+				return ErrorImportKey
+			}
+			children[k] = child
+		}
+	case []interface{}:
+		for i, child := range val {
+			children[strconv.Itoa(i)] = child
+		}
+	default:
+		kvs[dir] = documentValue(val)
+		return nil
+	}
+	for k, child := range children {
+		key := dir + "/" + k
+		if isContainer(child) {
+			if typeHint(key, hints) == TYPE_JSON {
+				out, err := json.Marshal(child)
+				if err != nil {
+					return err
+				}
+				kvs[key] = string(out)
+				continue
+			}
+			kvs[key] = DEFAULT_DIR_VALUE
+		}
+		if err := flattenDocument(key, child, kvs, hints); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isContainer(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+// 文档中的标量转为etcd中保存的字符串
+func documentValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// Import 将文档导入到prefix下,自动创建前缀本身以及上级目录
+// 变更不超过 MAX_TXN_OPS 时在一个事务中完成,否则分批写入,某一批失败时撤销已写入的批次
+func (c *Etcd3Client) Import(prefix string, doc map[string]interface{}, opt *ImportOption) (*ImportResult, error) {
+	if opt == nil {
+		opt = new(ImportOption)
+	}
+	switch opt.Mode {
+	case "":
+		opt.Mode = IMPORT_MERGE
+	case IMPORT_MERGE, IMPORT_REPLACE:
+	default:
+		return nil, ErrorImportMode
+	}
+	prefix = strings.TrimRight(prefix, "/")
+	want, err := FlattenDocument(prefix, doc, opt.Hints)
+	if err != nil {
+		return nil, err
+	}
+	// 前缀本身以及上级目录
+	for key := prefix; ; {
+		k, parentKey := c.ensureKey(key)
+		want[k] = DEFAULT_DIR_VALUE
+		if parentKey == "" {
+			break
+		}
+		key = parentKey
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// 读取当前值
+	dir := prefix + "/"
+	resp, err := c.Client.Get(ctx, dir, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	rev := resp.Header.Revision
+	cur := kvsToMap(resp.Kvs)
+	revs := make(map[string]int64, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		revs[string(kv.Key)] = kv.ModRevision
+	}
+	for key := range want {
+		if strings.HasPrefix(key, dir) {
+			continue
+		}
+		r, err := c.Client.Get(ctx, key, clientv3.WithRev(rev))
+		if err != nil {
+			return nil, err
+		}
+		for _, kv := range r.Kvs {
+			cur[key] = string(kv.Value)
+			revs[key] = kv.ModRevision
+		}
+	}
+
+	ret := &ImportResult{
+		Changes: make([]*ImportChange, 0),
+		DryRun:  opt.DryRun,
+	}
+	for key, v := range want {
+		old, ok := cur[key]
+		if !ok {
+			ret.Changes = append(ret.Changes, &ImportChange{Key: key, Action: "create", New: v})
+			continue
+		}
+		if old == v {
+			continue
+		}
+		// 合并时不能用值覆盖目录,也不能用目录覆盖值
+		if opt.Mode == IMPORT_MERGE && (old == DEFAULT_DIR_VALUE || v == DEFAULT_DIR_VALUE) {
+			return nil, fmt.Errorf("%s: %v", key, ErrorKeyType)
+		}
+		ret.Changes = append(ret.Changes, &ImportChange{Key: key, Action: "update", Old: old, New: v})
+	}
+	if opt.Mode == IMPORT_REPLACE {
+		for key, old := range cur {
+			if _, ok := want[key]; !ok {
+				ret.Changes = append(ret.Changes, &ImportChange{Key: key, Action: "delete", Old: old})
+			}
+		}
+	}
+	// 目录在前写入
+	sort.Slice(ret.Changes, func(i, j int) bool { return ret.Changes[i].Key < ret.Changes[j].Key })
+
+	ret.Atomic = len(ret.Changes) <= MAX_TXN_OPS
+	ret.Batches = (len(ret.Changes) + MAX_TXN_OPS - 1) / MAX_TXN_OPS
+	if opt.DryRun || len(ret.Changes) == 0 {
+		return ret, nil
+	}
+
+	// 分批写入,每个key都校验读取之后没有被修改
+	for i := 0; i < len(ret.Changes); i += MAX_TXN_OPS {
+		batch := ret.Changes[i:]
+		if len(batch) > MAX_TXN_OPS {
+			batch = batch[:MAX_TXN_OPS]
+		}
+		cmp := make([]clientv3.Cmp, 0, len(batch))
+		ops := make([]clientv3.Op, 0, len(batch))
+		for _, ch := range batch {
+			cmp = append(cmp, clientv3.Compare(clientv3.ModRevision(ch.Key), "=", revs[ch.Key]))
+			if ch.Action == "delete" {
+				ops = append(ops, clientv3.OpDelete(ch.Key))
+			} else {
+				ops = append(ops, clientv3.OpPut(ch.Key, ch.New))
+			}
+		}
+		txnResp, err := c.Client.Txn(ctx).If(cmp...).Then(ops...).Commit()
+		if err == nil && !txnResp.Succeeded {
+			err = ErrorKeyModified
+		}
+		if err != nil {
+			if i == 0 {
+				return nil, err
+			}
+			c.revertImport(ret.Changes[:i])
+			return nil, ErrorImportAbort
+		}
+	}
+	return ret, nil
+}
+
+// 撤销已写入的变更
+func (c *Etcd3Client) revertImport(changes []*ImportChange) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for i := 0; i < len(changes); i += MAX_TXN_OPS {
+		batch := changes[i:]
+		if len(batch) > MAX_TXN_OPS {
+			batch = batch[:MAX_TXN_OPS]
+		}
+		ops := make([]clientv3.Op, 0, len(batch))
+		for _, ch := range batch {
+			if ch.Action == "create" {
+				ops = append(ops, clientv3.OpDelete(ch.Key))
+			} else {
+				ops = append(ops, clientv3.OpPut(ch.Key, ch.Old))
+			}
+		}
+		c.Client.Txn(ctx).Then(ops...).Commit()
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
 	"github.com/coreos/etcd/mvcc/mvccpb"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -23,9 +26,10 @@ func (c *Etcd3Client) GetRecursiveValue(key string) (list []*Node, err error) {
 	// 过滤掉目录
 	for _, kv := range resp.Kvs {
 		list = append(list, &Node{
-			Value:   string(kv.Value),
-			FullDir: string(kv.Key),
-			Version: kv.Version,
+			Value:       string(kv.Value),
+			FullDir:     string(kv.Key),
+			Version:     kv.Version,
+			ModRevision: kv.ModRevision,
 		})
 	}
 	//for _,node:=range list{
@@ -57,25 +61,53 @@ func (c *Etcd3Client) Delete(key string) error {
 
 // 通过key 获取value
 func (c *Etcd3Client) Value(key string) (val *Node, err error) {
+	return c.ValueWithRev(key, 0)
+}
+
+// ValueWithRev 获取key在指定版本时的value, rev为0时获取最新值
+func (c *Etcd3Client) ValueWithRev(key string, rev int64) (val *Node, err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	resp, err := c.Client.Get(ctx, key)
+	resp, err := c.Client.Get(ctx, key, clientv3.WithRev(rev))
 	if err != nil {
 		return
 	}
 	if resp.Kvs != nil && len(resp.Kvs) > 0 {
 		val = &Node{
-			Value:   string(resp.Kvs[0].Value),
-			FullDir: key,
-			Version: resp.Kvs[0].Version,
+			Value:       string(resp.Kvs[0].Value),
+			FullDir:     key,
+			Version:     resp.Kvs[0].Version,
+			ModRevision: resp.Kvs[0].ModRevision,
+			Lease:       formatLeaseID(resp.Kvs[0].Lease),
 		}
+		err = c.fillLease([]*Node{val})
 	} else {
 		err = ErrorKeyNotFound
 	}
 	return
 }
 
+// 目录本身以及目录下所有key的读取范围, key为空时为全部key
+// 范围内还包括 /app 与 /app/ 之间的 /app-x 等同级的key,需要再通过 underPrefix 过滤
+func dirRange(key string) (string, string) {
+	if key == "" {
+		return "\x00", "\x00"
+	}
+	if key = strings.TrimRight(key, "/"); key == "" {
+		return "/", clientv3.GetPrefixRangeEnd("/")
+	}
+	return key, clientv3.GetPrefixRangeEnd(key + "/")
+}
+
+// key是否为目录本身或在其之下,按路径判断, /app 之下不包括 /apple, prefix为空时为全部key
+func underPrefix(prefix, key string) bool {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(key, prefix) || key == strings.TrimSuffix(prefix, "/")
+	}
+	return key == prefix || strings.HasPrefix(key, prefix+"/")
+}
+
 // 返回key以及父路径
 func (c *Etcd3Client) ensureKey(key string) (string, string) {
 	key = strings.TrimRight(key, "/") // 去掉右边的 / , 比如 /etc/java/ 变成 /etc/java
@@ -92,8 +124,32 @@ func (c *Etcd3Client) ensureKey(key string) (string, string) {
 
 // Put 添加一个key
 func (c *Etcd3Client) Put(key string, value string, mustEmpty bool) error {
+	_, err := c.PutWithOption(key, value, mustEmpty, nil)
+	return err
+}
+
+// PutWithOption 添加或修改一个key, opt为nil时与Put相同
+// 版本不一致时返回 ErrorKeyConflict 以及key当前的值(key已被删除时为nil)
+func (c *Etcd3Client) PutWithOption(key string, value string, mustEmpty bool, opt *PutOption) (*Node, error) {
+	if opt == nil {
+		opt = new(PutOption)
+	}
 
 	key, parentKey := c.ensureKey(key)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 不带版本修改时key可能已被删除,此时重新创建
+	// key不存在时 Value 条件总是不成立, etcd也会拒绝 WithIgnoreLease
+	exists := true
+	if !mustEmpty && opt.KeepLease && opt.ModRevision == 0 {
+		resp, err := c.Client.Get(ctx, key, clientv3.WithCountOnly())
+		if err != nil {
+			return nil, err
+		}
+		exists = resp.Count > 0
+	}
+
 	//  需要判断的条件
 	cmp := make([]clientv3.Cmp, 0)
 
@@ -104,35 +160,96 @@ func (c *Etcd3Client) Put(key string, value string, mustEmpty bool) error {
 	if mustEmpty {
 		//c := clientv3.Compare(clientv3.Value(key), "=", "")
 		//cmp = append(cmp, c)
-	} else {
+	} else if exists {
 		c := clientv3.Compare(clientv3.Value(key), "!=", DEFAULT_DIR_VALUE)
 		cmp = append(cmp, c)
+	} else { // 重新创建时key仍不存在
+		c := clientv3.Compare(clientv3.Version(key), "=", 0)
+		cmp = append(cmp, c)
+	}
+	if opt.ModRevision > 0 { // 乐观锁,防止覆盖他人的修改
+		c := clientv3.Compare(clientv3.ModRevision(key), "=", opt.ModRevision)
+		cmp = append(cmp, c)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// 租约
+	putOpts := make([]clientv3.OpOption, 0)
+	var leaseID clientv3.LeaseID
+	if opt.TTL > 0 {
+		leaseResp, err := c.Client.Grant(ctx, opt.TTL)
+		if err != nil {
+			return nil, err
+		}
+		leaseID = leaseResp.ID
+		putOpts = append(putOpts, clientv3.WithLease(leaseID))
+	} else if opt.KeepLease && exists {
+		putOpts = append(putOpts, clientv3.WithIgnoreLease())
+	}
 	// 创建事物
 	txn := c.Client.Txn(ctx)
 	txn.If( // 条件判断
 		cmp...
 	).Then( // 事物操作
-		clientv3.OpPut(key, value),
+		clientv3.OpPut(key, value, putOpts...),
+	).Else( // 失败时取回当前值,用于判断失败原因
+		clientv3.OpGet(key),
 	)
 	// 提交事物
 	txnResp, err := txn.Commit()
+	if (err != nil || !txnResp.Succeeded) && leaseID != 0 {
+		// 未写入成功,回收刚创建的租约
+		c.Client.Revoke(ctx, leaseID)
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if !txnResp.Succeeded { // 添加失败
-		return ErrorPutKey
+		if opt.ModRevision > 0 && len(txnResp.Responses) > 0 {
+			kvs := txnResp.Responses[0].GetResponseRange().Kvs
+			if len(kvs) == 0 {
+				return nil, ErrorKeyConflict
+			}
+			if kvs[0].ModRevision != opt.ModRevision {
+				return &Node{
+					Value:       string(kvs[0].Value),
+					FullDir:     key,
+					Version:     kvs[0].Version,
+					ModRevision: kvs[0].ModRevision,
+				}, ErrorKeyConflict
+			}
+		}
+		return nil, ErrorPutKey
 	}
-	return nil
+	return nil, nil
 }
 
+// List 获取目录下一级的key列表
 func (c *Etcd3Client) List(key string) (nodes []*Node, err error) {
-	nodes = make([]*Node, 0)
+	return c.ListWithRev(key, 0)
+}
+
+// ListWithRev 获取目录在指定版本时下一级的key列表, rev为0时获取最新数据
+func (c *Etcd3Client) ListWithRev(key string, rev int64) (nodes []*Node, err error) {
+	page, err := c.ListPage(key, &ListOption{Rev: rev})
+	if err != nil {
+		return nil, err
+	}
+	return page.List, nil
+}
+
+// ListPage 分页获取目录下一级的key列表
+// 按key正序时以下一个key作为游标逐页扫描,其它排序需要取出目录下一级的全部key后排序,游标为偏移量
+// 扫描时只取key不取值,遇到子目录中的key直接跳过整个子目录
+func (c *Etcd3Client) ListPage(key string, opt *ListOption) (*ListPage, error) {
 	if key == "" {
-		return nodes, errors.New("key is empty")
+		return nil, errors.New("key is empty")
+	}
+	if opt == nil {
+		opt = new(ListOption)
+	}
+	sortBy, desc, err := parseListSort(opt.Sort)
+	if err != nil {
+		return nil, err
 	}
 	// 兼容key前缀设置为 /
 	dir := key
@@ -142,40 +259,200 @@ func (c *Etcd3Client) List(key string) (nodes []*Node, err error) {
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	// 创建事物
-	txn := c.Client.Txn(ctx)
-	txnResp, err := txn.If( // 条件判断
-		//clientv3.Compare(clientv3.Value(key), "=", DEFAULT_DIR_VALUE),
-	).Then( // 事物操作
-		clientv3.OpGet(dir, clientv3.WithPrefix()),
-	).Commit()
 
+	// 统计目录下所有层级key的数量,同时确定本次读取的版本
+	countResp, err := c.Client.Get(ctx, dir, clientv3.WithPrefix(), clientv3.WithCountOnly(), clientv3.WithRev(opt.Rev))
 	if err != nil {
 		return nil, err
 	}
+	rev := opt.Rev
+	if rev == 0 {
+		rev = countResp.Header.Revision
+	}
+	page := &ListPage{
+		List:     make([]*Node, 0),
+		Revision: rev,
+		Count:    countResp.Count,
+	}
 
-	if txnResp.Succeeded {
-		if len(txnResp.Responses) > 0 {
-			rangeResp := txnResp.Responses[0].GetResponseRange()
-			return c.list(dir, rangeResp.Kvs)
-		} else {
-			// empty directory
-			return []*Node{}, nil
+	var kvs []*mvccpb.KeyValue
+	if sortBy == LIST_SORT_KEY && !desc {
+		start := dir
+		if opt.Cursor != "" {
+			if !strings.HasPrefix(opt.Cursor, dir) {
+				return nil, ErrorListCursor
+			}
+			start = opt.Cursor
+		}
+		kvs, page.Cursor, err = c.scanChildren(ctx, dir, start, rev, opt.Limit)
+		if err != nil {
+			return nil, err
 		}
 	} else {
-		return nil, ErrorListKey
+		kvs, _, err = c.scanChildren(ctx, dir, dir, rev, 0)
+		if err != nil {
+			return nil, err
+		}
+		sortKvs(kvs, sortBy, desc)
+		offset := 0
+		if opt.Cursor != "" {
+			offset, err = strconv.Atoi(opt.Cursor)
+			if err != nil || offset < 0 {
+				return nil, ErrorListCursor
+			}
+		}
+		if offset > len(kvs) {
+			offset = len(kvs)
+		}
+		end := len(kvs)
+		if opt.Limit > 0 && offset+opt.Limit < len(kvs) {
+			end = offset + opt.Limit
+			page.Cursor = strconv.Itoa(end)
+		}
+		kvs = kvs[offset:end]
+	}
+
+	page.List, err = c.listNodes(ctx, dir, rev, kvs)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.fillLease(page.List); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// 从start开始按key正序扫描dir下一级的key(只取key),limit为0时不限制数量
+// 返回的游标为下一页开始的key,为空表示已扫描完
+func (c *Etcd3Client) scanChildren(ctx context.Context, dir, start string, rev int64, limit int) ([]*mvccpb.KeyValue, string, error) {
+	end := clientv3.GetPrefixRangeEnd(dir)
+	kvs := make([]*mvccpb.KeyValue, 0)
+	for {
+		resp, err := c.Client.Get(ctx, start,
+			clientv3.WithRange(end),
+			clientv3.WithKeysOnly(),
+			clientv3.WithLimit(LIST_SCAN_BATCH),
+			clientv3.WithRev(rev),
+		)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(resp.Kvs) == 0 {
+			return kvs, "", nil
+		}
+		skipped := false
+		for _, kv := range resp.Kvs {
+			name := strings.TrimPrefix(string(kv.Key), dir)
+			if name == "" {
+				continue
+			}
+			if i := strings.Index(name, "/"); i >= 0 {
+				// secondary directory, 跳过整个子目录
+				start = clientv3.GetPrefixRangeEnd(dir + name[:i+1])
+				skipped = true
+				break
+			}
+			if limit > 0 && len(kvs) == limit {
+				return kvs, string(kv.Key), nil
+			}
+			kvs = append(kvs, kv)
+		}
+		if !skipped {
+			if !resp.More {
+				return kvs, "", nil
+			}
+			start = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+		}
 	}
 }
 
-func (c *Etcd3Client) list(dir string, kvs []*mvccpb.KeyValue) ([]*Node, error) {
-	nodes := make([]*Node, 0)
-	for _, kv := range kvs {
-		name := strings.TrimPrefix(string(kv.Key), dir)
-		if strings.Contains(name, "/") {
-			// secondary directory
-			continue
+// 取出key的值生成节点列表,每个事务最多 MAX_TXN_OPS 个key
+func (c *Etcd3Client) listNodes(ctx context.Context, dir string, rev int64, kvs []*mvccpb.KeyValue) ([]*Node, error) {
+	nodes := make([]*Node, 0, len(kvs))
+	for i := 0; i < len(kvs); i += MAX_TXN_OPS {
+		batch := kvs[i:]
+		if len(batch) > MAX_TXN_OPS {
+			batch = batch[:MAX_TXN_OPS]
+		}
+		ops := make([]clientv3.Op, 0, len(batch))
+		for _, kv := range batch {
+			ops = append(ops, clientv3.OpGet(string(kv.Key), clientv3.WithRev(rev)))
+		}
+		txnResp, err := c.Client.Txn(ctx).Then(ops...).Commit()
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range txnResp.Responses {
+			for _, kv := range r.GetResponseRange().Kvs {
+				nodes = append(nodes, NewNode(dir, kv))
+			}
 		}
-		nodes = append(nodes, NewNode(dir, kv))
 	}
 	return nodes, nil
 }
+
+// 解析排序参数,字段前加 - 为倒序
+func parseListSort(s string) (string, bool, error) {
+	desc := strings.HasPrefix(s, "-")
+	sortBy := strings.TrimPrefix(s, "-")
+	switch sortBy {
+	case "":
+		return LIST_SORT_KEY, desc, nil
+	case LIST_SORT_KEY, LIST_SORT_VERSION, LIST_SORT_MOD_REVISION:
+		return sortBy, desc, nil
+	}
+	return "", false, ErrorListSort
+}
+
+// 按字段排序,相同时按key排序
+func sortKvs(kvs []*mvccpb.KeyValue, sortBy string, desc bool) {
+	sort.SliceStable(kvs, func(i, j int) bool {
+		a, b := kvs[i], kvs[j]
+		if desc {
+			a, b = b, a
+		}
+		switch sortBy {
+		case LIST_SORT_VERSION:
+			if a.Version != b.Version {
+				return a.Version < b.Version
+			}
+		case LIST_SORT_MOD_REVISION:
+			if a.ModRevision != b.ModRevision {
+				return a.ModRevision < b.ModRevision
+			}
+		}
+		return string(a.Key) < string(b.Key)
+	})
+}
+
+// History 获取key的历史版本,从rev(为0时从最新版本)开始按修改版本倒序向前遍历
+// 遍历到key的创建版本或已被压缩的版本时结束, limit为0时不限制条数
+func (c *Etcd3Client) History(key string, rev int64, limit int) (list []*KeyHistory, err error) {
+	list = make([]*KeyHistory, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for limit <= 0 || len(list) < limit {
+		resp, err := c.Client.Get(ctx, key, clientv3.WithRev(rev))
+		if err != nil {
+			// 更早的版本已被压缩,返回已获取到的部分
+			if err == rpctypes.ErrCompacted && len(list) > 0 {
+				break
+			}
+			return nil, err
+		}
+		if len(resp.Kvs) == 0 {
+			if len(list) == 0 {
+				return nil, ErrorKeyNotFound
+			}
+			break
+		}
+		kv := resp.Kvs[0]
+		list = append(list, NewKeyHistory(kv))
+		if kv.Version <= 1 { // 已到创建版本
+			break
+		}
+		rev = kv.ModRevision - 1
+	}
+	return list, nil
+}
@@ -0,0 +1,89 @@
+package etcdv3
+
+import (
+	"context"
+	"fmt"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/etcdserver/etcdserverpb"
+	"github.com/qiuhoude/etcd-manage/program/config"
+	"time"
+)
+
+// MoveLeader 将leader转移到id节点
+// 转移请求只能由当前leader处理,所以需要etcdCfg单独创建一个连接leader的客户端
+func (c *Etcd3Client) MoveLeader(etcdCfg *config.EtcdServer, id uint64) (*MoveLeaderResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := c.Client.MemberList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var target *etcdserverpb.Member
+	for _, m := range resp.Members {
+		if m.ID == id {
+			target = m
+		}
+	}
+	if target == nil {
+		return nil, ErrorMemberNotFound
+	}
+	// 目标节点必须是健康的,etcd v3.3 没有learner节点,所有节点都有投票权
+	if len(target.ClientURLs) == 0 {
+		return nil, ErrorLeaderTarget
+	}
+	status, err := c.Client.Status(ctx, target.ClientURLs[0])
+	if err != nil {
+		return nil, ErrorLeaderTarget
+	}
+	if status.Leader == id {
+		return nil, ErrorAlreadyLeader
+	}
+	var leader *etcdserverpb.Member
+	for _, m := range resp.Members {
+		if m.ID == status.Leader {
+			leader = m
+		}
+	}
+	if leader == nil || len(leader.ClientURLs) == 0 {
+		return nil, ErrorNoLeader
+	}
+
+	cliCfg, err := newClientConfig(etcdCfg, leader.ClientURLs)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := clientv3.New(cliCfg)
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+	mctx, mcancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer mcancel()
+	if _, err = cli.MoveLeader(mctx, id); err != nil {
+		return nil, err
+	}
+
+	ret := &MoveLeaderResult{
+		From: fmt.Sprintf("%x", leader.ID),
+		To:   fmt.Sprintf("%x", id),
+	}
+	// 转移完成后各节点更新leader信息需要一点时间
+	for i := 0; i < 10; i++ {
+		sctx, scancel := context.WithTimeout(context.Background(), 2*time.Second)
+		status, err := c.Client.Status(sctx, target.ClientURLs[0])
+		scancel()
+		if err == nil && status.Leader != 0 {
+			ret.Leader = fmt.Sprintf("%x", status.Leader)
+			for _, m := range resp.Members {
+				if m.ID == status.Leader {
+					ret.LeaderName = m.Name
+				}
+			}
+			if status.Leader == id {
+				break
+			}
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return ret, nil
+}
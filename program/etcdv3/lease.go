@@ -0,0 +1,180 @@
+package etcdv3
+
+import (
+	"context"
+	"errors"
+	"github.com/coreos/etcd/clientv3"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 并行查询租约剩余时间的数量
+const LEASE_CONCURRENCY = 8
+
+// Leases 获取租约列表
+func (c *Etcd3Client) Leases() ([]*Lease, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := c.Client.Leases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]clientv3.LeaseID, 0, len(resp.Leases))
+	for _, l := range resp.Leases {
+		ids = append(ids, l.ID)
+	}
+	ttls, err := c.timeToLive(ids, clientv3.WithAttachedKeys())
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*Lease, 0, len(ttls))
+	for _, ttlResp := range ttls {
+		list = append(list, newLease(ttlResp))
+	}
+	return list, nil
+}
+
+// 并行查询租约的剩余时间,每次查询单独超时,任意一个失败时返回错误
+func (c *Etcd3Client) timeToLive(ids []clientv3.LeaseID, opts ...clientv3.LeaseOption) ([]*clientv3.LeaseTimeToLiveResponse, error) {
+	ret := make([]*clientv3.LeaseTimeToLiveResponse, len(ids))
+	errs := make([]error, len(ids))
+	sem := make(chan struct{}, LEASE_CONCURRENCY)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, id clientv3.LeaseID) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			ret[i], errs[i] = c.Client.TimeToLive(ctx, id, opts...)
+		}(i, id)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// LeaseInfo 获取租约信息以及绑定的key
+func (c *Etcd3Client) LeaseInfo(id string) (*Lease, error) {
+	leaseID, err := parseLeaseID(id)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := c.Client.TimeToLive(ctx, leaseID, clientv3.WithAttachedKeys())
+	if err != nil {
+		return nil, err
+	}
+	return newLease(resp), nil
+}
+
+// LeaseKeepAliveOnce 续约一次
+func (c *Etcd3Client) LeaseKeepAliveOnce(id string) (*Lease, error) {
+	leaseID, err := parseLeaseID(id)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := c.Client.KeepAliveOnce(ctx, leaseID)
+	if err != nil {
+		return nil, err
+	}
+	return &Lease{
+		ID:         formatLeaseID(int64(resp.ID)),
+		TTL:        resp.TTL,
+		GrantedTTL: resp.TTL,
+		Keys:       make([]string, 0),
+	}, nil
+}
+
+// LeaseRevoke 撤销租约,绑定的key会被一起删除
+func (c *Etcd3Client) LeaseRevoke(id string) error {
+	leaseID, err := parseLeaseID(id)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = c.Client.Revoke(ctx, leaseID)
+	return err
+}
+
+// 为绑定了租约的节点填充剩余时间,同一个租约只查询一次
+func (c *Etcd3Client) fillLease(nodes []*Node) error {
+	ids := make([]clientv3.LeaseID, 0)
+	seen := make(map[string]bool, 0)
+	for _, n := range nodes {
+		if n.Lease == "" || seen[n.Lease] {
+			continue
+		}
+		seen[n.Lease] = true
+		leaseID, err := parseLeaseID(n.Lease)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, leaseID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	ttls, err := c.timeToLive(ids)
+	if err != nil {
+		return err
+	}
+	// 租约已过期时TTL为-1
+	m := make(map[string]int64, len(ttls))
+	for i, resp := range ttls {
+		m[formatLeaseID(int64(ids[i]))] = resp.TTL
+	}
+	for _, n := range nodes {
+		if n.Lease != "" {
+			n.TTL = m[n.Lease]
+		}
+	}
+	return nil
+}
+
+func newLease(resp *clientv3.LeaseTimeToLiveResponse) *Lease {
+	l := &Lease{
+		ID:         formatLeaseID(int64(resp.ID)),
+		TTL:        resp.TTL,
+		GrantedTTL: resp.GrantedTTL,
+		Keys:       make([]string, 0, len(resp.Keys)),
+	}
+	for _, k := range resp.Keys {
+		l.Keys = append(l.Keys, string(k))
+	}
+	return l
+}
+
+// 租约ID格式化为十六进制,没有租约时为空
+func formatLeaseID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(id, 16)
+}
+
+// 解析十六进制的租约ID
+func parseLeaseID(id string) (clientv3.LeaseID, error) {
+	v, err := strconv.ParseInt(id, 16, 64)
+	if err != nil || v == 0 {
+		return 0, errors.New("invalid lease id")
+	}
+	return clientv3.LeaseID(v), nil
+}
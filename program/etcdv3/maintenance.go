@@ -0,0 +1,169 @@
+package etcdv3
+
+import (
+	"context"
+	"fmt"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/etcdserver/etcdserverpb"
+	"strings"
+	"time"
+)
+
+// 整理单个节点的超时时间,数据量大时整理比较慢
+const DEFRAG_TIMEOUT = 10 * time.Minute
+
+// Compact 压缩历史版本,keep大于0时保留最近keep个版本
+// physical为true时等待压缩在所有节点上完成后才返回
+func (c *Etcd3Client) Compact(rev, keep int64, physical bool) (*CompactResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	resp, err := c.Client.Get(ctx, "/", clientv3.WithCountOnly())
+	if err != nil {
+		return nil, err
+	}
+	current := resp.Header.Revision
+	if keep > 0 {
+		rev = current - keep
+	}
+	if rev <= 0 {
+		return nil, ErrorCompactRev
+	}
+	if rev > current {
+		return nil, ErrorFutureRev
+	}
+
+	opts := make([]clientv3.CompactOption, 0)
+	if physical {
+		opts = append(opts, clientv3.WithCompactPhysical())
+	}
+	if _, err = c.Client.Compact(ctx, rev, opts...); err != nil {
+		return nil, err
+	}
+	return &CompactResult{
+		Revision:        rev,
+		CurrentRevision: current,
+	}, nil
+}
+
+// Defragment 依次整理节点,id为0时整理所有节点,每个节点开始和结束时调用progress
+// 整理期间节点无法读写,所以一次只整理一个节点
+func (c *Etcd3Client) Defragment(ctx context.Context, id uint64, progress func(*DefragProgress)) error {
+	lctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	resp, err := c.Client.MemberList(lctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	members := make([]*etcdserverpb.Member, 0)
+	for _, m := range resp.Members {
+		if id == 0 || m.ID == id {
+			members = append(members, m)
+		}
+	}
+	if len(members) == 0 {
+		return ErrorMemberNotFound
+	}
+
+	for i, m := range members {
+		p := &DefragProgress{
+			MemberID: fmt.Sprintf("%x", m.ID),
+			Name:     m.Name,
+			Index:    i + 1,
+			Total:    len(members),
+			Status:   DEFRAG_RUNNING,
+		}
+		if len(m.ClientURLs) == 0 {
+			p.Status = DEFRAG_FAILED
+			p.Error = "member has not started"
+			progress(p)
+			continue
+		}
+		p.Endpoint = m.ClientURLs[0]
+		p.DbSizeBefore = c.dbSize(ctx, p.Endpoint)
+		progress(p)
+
+		dctx, cancel := context.WithTimeout(ctx, DEFRAG_TIMEOUT)
+		start := time.Now()
+		_, err := c.Client.Defragment(dctx, p.Endpoint)
+		cancel()
+		p.Elapsed = time.Since(start).Seconds()
+		if err != nil {
+			p.Status = DEFRAG_FAILED
+			p.Error = err.Error()
+		} else {
+			p.Status = DEFRAG_DONE
+			p.DbSizeAfter = c.dbSize(ctx, p.Endpoint)
+		}
+		progress(p)
+		if ctx.Err() != nil { // 客户端已断开,不再整理剩下的节点
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// 获取节点的数据库大小,获取失败时返回0
+func (c *Etcd3Client) dbSize(ctx context.Context, endpoint string) int64 {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	resp, err := c.Client.Status(ctx, endpoint)
+	if err != nil {
+		return 0
+	}
+	return resp.DbSize
+}
+
+// Alarms 获取集群中的告警
+func (c *Etcd3Client) Alarms() ([]*Alarm, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := c.Client.AlarmList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newAlarms(resp.Alarms), nil
+}
+
+// AlarmDisarm 解除告警,id为0时解除所有节点的告警,alarm为空时解除所有类型的告警
+// 返回解除的告警
+func (c *Etcd3Client) AlarmDisarm(id uint64, alarm string) ([]*Alarm, error) {
+	var alarmType etcdserverpb.AlarmType
+	if alarm != "" {
+		v, ok := etcdserverpb.AlarmType_value[strings.ToUpper(alarm)]
+		if !ok || v == int32(etcdserverpb.AlarmType_NONE) {
+			return nil, ErrorAlarmType
+		}
+		alarmType = etcdserverpb.AlarmType(v)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := c.Client.AlarmList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	disarmed := make([]*etcdserverpb.AlarmMember, 0)
+	for _, a := range resp.Alarms {
+		if (id != 0 && a.MemberID != id) || (alarm != "" && a.Alarm != alarmType) {
+			continue
+		}
+		_, err := c.Client.AlarmDisarm(ctx, (*clientv3.AlarmMember)(a))
+		if err != nil {
+			return newAlarms(disarmed), err
+		}
+		disarmed = append(disarmed, a)
+	}
+	return newAlarms(disarmed), nil
+}
+
+func newAlarms(list []*etcdserverpb.AlarmMember) []*Alarm {
+	alarms := make([]*Alarm, 0, len(list))
+	for _, a := range list {
+		alarms = append(alarms, &Alarm{
+			MemberID: fmt.Sprintf("%x", a.MemberID),
+			Alarm:    a.Alarm.String(),
+		})
+	}
+	return alarms
+}
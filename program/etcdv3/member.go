@@ -3,10 +3,14 @@ package etcdv3
 import (
 	"context"
 	"fmt"
+	"github.com/coreos/etcd/etcdserver/etcdserverpb"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 )
 
-// Members 获取节点列表
+// Members 获取节点列表,并行获取每个节点的状态
 func (c *Etcd3Client) Members() ([]*Member, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -15,32 +19,162 @@ func (c *Etcd3Client) Members() ([]*Member, error) {
 	if err != nil {
 		return nil, err
 	}
-	members := make([]*Member, 0)
-	for _, member := range resp.Members {
-		if len(member.ClientURLs) > 0 {
-			mm := member
-			func() {
-				ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-				defer cancel()
-				m := &Member{
-					Member: mm,
-					Role:   ROLE_FOLLOWER,
-					Status: STATUS_UNHEALTHY,
-				}
-				fmt.Println("url -> " + m.ClientURLs[0])
-				resp, err := c.Client.Status(ctx, m.ClientURLs[0])
-				if err == nil {
-					m.Status = STATUS_HEALTHY
-					m.DbSize = resp.DbSize
-					if resp.Leader == resp.Header.MemberId {
-						m.Role = ROLE_LEADER
-					}
-				}
-				members = append(members, m)
-			}()
+	// 告警是集群级别的,按节点分组
+	alarms := make(map[uint64][]string, 0)
+	if aresp, err := c.Client.AlarmList(ctx); err == nil {
+		for _, a := range aresp.Alarms {
+			alarms[a.MemberID] = append(alarms[a.MemberID], a.Alarm.String())
+		}
+	}
 
+	members := make([]*Member, len(resp.Members))
+	var wg sync.WaitGroup
+	for i, member := range resp.Members {
+		m := &Member{
+			Member:   member,
+			MemberID: fmt.Sprintf("%x", member.ID),
+			Role:     ROLE_FOLLOWER,
+			Status:   STATUS_UNHEALTHY,
+			Alarms:   alarms[member.ID],
+		}
+		if m.Alarms == nil {
+			m.Alarms = []string{}
+		}
+		members[i] = m
+		if len(member.ClientURLs) == 0 {
+			m.Status = STATUS_UNSTARTED
+			continue
 		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.memberStatus(m)
+		}()
 	}
+	wg.Wait()
 	return members, nil
+}
+
+// 获取节点状态,依次尝试节点的client地址
+func (c *Etcd3Client) memberStatus(m *Member) {
+	for _, u := range m.ClientURLs {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		start := time.Now()
+		resp, err := c.Client.Status(ctx, u)
+		cancel()
+		if err != nil {
+			m.Error = err.Error()
+			continue
+		}
+		m.Status = STATUS_HEALTHY
+		m.Error = ""
+		m.Latency = float64(time.Since(start).Microseconds()) / 1000
+		m.DbSize = resp.DbSize
+		m.Version = resp.Version
+		m.Leader = fmt.Sprintf("%x", resp.Leader)
+		m.RaftTerm = resp.RaftTerm
+		m.RaftIndex = resp.RaftIndex
+		if resp.Leader == resp.Header.MemberId {
+			m.Role = ROLE_LEADER
+		}
+		return
+	}
+}
+
+// MemberAdd 添加节点,name为新节点的名称,用于生成新节点启动时的 --initial-cluster 参数
+// etcd v3.3 不支持learner节点
+func (c *Etcd3Client) MemberAdd(name string, peerURLs []string, isLearner bool) (*MemberAddResult, error) {
+	if isLearner {
+		return nil, ErrorLearner
+	}
+	if err := checkPeerURLs(peerURLs); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := c.Client.MemberAdd(ctx, peerURLs)
+	if err != nil {
+		return nil, err
+	}
+	ret := &MemberAddResult{
+		Member:  resp.Member,
+		Members: resp.Members,
+	}
+	conf := make([]string, 0)
+	for _, m := range resp.Members {
+		mName := m.Name
+		if m.ID == resp.Member.ID {
+			mName = name
+		}
+		if mName == "" { // 还没有启动的节点没有名称
+			continue
+		}
+		for _, u := range m.PeerURLs {
+			conf = append(conf, fmt.Sprintf("%s=%s", mName, u))
+		}
+	}
+	ret.InitialCluster = strings.Join(conf, ",")
+	return ret, nil
+}
 
+// MemberRemove 删除节点
+func (c *Etcd3Client) MemberRemove(id uint64) error {
+	if _, err := c.member(id); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := c.Client.MemberRemove(ctx, id)
+	return err
+}
+
+// MemberUpdate 更新节点的peer地址
+func (c *Etcd3Client) MemberUpdate(id uint64, peerURLs []string) error {
+	if err := checkPeerURLs(peerURLs); err != nil {
+		return err
+	}
+	if _, err := c.member(id); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := c.Client.MemberUpdate(ctx, id, peerURLs)
+	return err
+}
+
+// MemberPromote 将learner节点提升为有投票权的节点
+// etcd v3.3 不支持learner节点,升级到v3.4之前总是返回 ErrorLearner
+func (c *Etcd3Client) MemberPromote(id uint64) error {
+	return ErrorLearner
+}
+
+// 获取指定id的节点
+func (c *Etcd3Client) member(id uint64) (*etcdserverpb.Member, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := c.Client.MemberList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range resp.Members {
+		if m.ID == id {
+			return m, nil
+		}
+	}
+	return nil, ErrorMemberNotFound
+}
+
+// 检查peer地址
+func checkPeerURLs(peerURLs []string) error {
+	if len(peerURLs) == 0 {
+		return ErrorPeerURL
+	}
+	for _, v := range peerURLs {
+		u, err := url.Parse(v)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrorPeerURL
+		}
+	}
+	return nil
 }
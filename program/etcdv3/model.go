@@ -1,6 +1,7 @@
 package etcdv3
 
 import (
+	"github.com/coreos/etcd/clientv3"
 	"github.com/coreos/etcd/etcdserver/etcdserverpb"
 	"github.com/coreos/etcd/mvcc/mvccpb"
 	"strings"
@@ -12,32 +13,395 @@ const (
 
 	STATUS_HEALTHY   = "healthy"
 	STATUS_UNHEALTHY = "unhealthy"
+	STATUS_UNSTARTED = "unstarted" // 已添加但还没有启动的节点,没有client地址
 
 	// 目录的默认值
 	DEFAULT_DIR_VALUE = "etcdv3_dir_$2H#%gRe3*t"
+
+	// 单个事务中允许的最大操作数,与etcd默认的 --max-txn-ops 一致
+	MAX_TXN_OPS = 128
+
+	// 复制或移动时目标key已存在的处理方式
+	CONFLICT_OVERWRITE = "overwrite" // 覆盖
+	CONFLICT_SKIP      = "skip"      // 跳过,移动时保留源key
+	CONFLICT_FAIL      = "fail"      // 放弃整个操作
+
+	// 导入时的模式
+	IMPORT_MERGE   = "merge"   // 合并,只写入文档中的key
+	IMPORT_REPLACE = "replace" // 替换,同时删除前缀下文档中不存在的key
+
+	// 格式化时的类型提示
+	TYPE_STRING = "string"
+	TYPE_NUMBER = "number"
+	TYPE_INT    = "int"
+	TYPE_BOOL   = "bool"
+	TYPE_JSON   = "json"
+
+	// 备份文件格式
+	BACKUP_FORMAT  = "etcd-manage-backup"
+	BACKUP_VERSION = 1
+	// 恢复时备份文件解压后的最大字节数以及最多的key数量
+	BACKUP_MAX_SIZE = 512 << 20
+	BACKUP_MAX_KEYS = 1000000
+
+	// 列表扫描时每次读取的key数量
+	LIST_SCAN_BATCH = 1000
+
+	// 整理节点的进度状态
+	DEFRAG_RUNNING = "running"
+	DEFRAG_DONE    = "done"
+	DEFRAG_FAILED  = "failed"
+
+	// etcd用户权限类型
+	AUTH_PERM_READ      = "read"
+	AUTH_PERM_WRITE     = "write"
+	AUTH_PERM_READWRITE = "readwrite"
+
+	// 列表排序字段
+	LIST_SORT_KEY          = "key"
+	LIST_SORT_VERSION      = "version"
+	LIST_SORT_MOD_REVISION = "mod_revision"
 )
 
 // Member 节点信息
 type Member struct {
 	*etcdserverpb.Member
-	Role   string `json:"role"`
-	Status string `json:"status"`
-	DbSize int64  `json:"db_size"`
+	MemberID  string   `json:"member_id"` // 十六进制的节点id,与etcdctl一致
+	Role      string   `json:"role"`
+	Status    string   `json:"status"`
+	DbSize    int64    `json:"db_size"`
+	Version   string   `json:"version"`
+	Leader    string   `json:"leader"` // 此节点认为的leader节点id(十六进制)
+	RaftTerm  uint64   `json:"raft_term"`
+	RaftIndex uint64   `json:"raft_index"`
+	Latency   float64  `json:"latency"` // 获取状态的耗时,毫秒
+	Alarms    []string `json:"alarms"`  // 此节点上的告警
+	Error     string   `json:"error,omitempty"`
 }
 
 // Node 需要使用到的模型
 type Node struct {
-	IsDir   bool   `json:"is_dir"`
-	Version int64  `json:"version,string"`
-	Value   string `json:"value"`
-	FullDir string `json:"full_dir"`
+	IsDir       bool   `json:"is_dir"`
+	Version     int64  `json:"version,string"`
+	ModRevision int64  `json:"mod_revision,string"` // 最后修改时的版本,修改时用于冲突检测
+	Value       string `json:"value"`
+	FullDir     string `json:"full_dir"`
+	Lease       string `json:"lease,omitempty"` // 绑定的租约ID(十六进制)
+	TTL         int64  `json:"ttl,omitempty"`   // 租约剩余秒数
 }
 
 func NewNode(dir string, kv *mvccpb.KeyValue) *Node {
 	return &Node{
-		IsDir:   string(kv.Value) == DEFAULT_DIR_VALUE,
-		Version: kv.Version,
-		Value:   strings.TrimPrefix(string(kv.Key), dir),
-		FullDir: string(kv.Key),
+		IsDir:       string(kv.Value) == DEFAULT_DIR_VALUE,
+		Version:     kv.Version,
+		ModRevision: kv.ModRevision,
+		Value:       strings.TrimPrefix(string(kv.Key), dir),
+		FullDir:     string(kv.Key),
+		Lease:       formatLeaseID(kv.Lease),
+	}
+}
+
+// PutOption 添加或修改key时的可选参数
+type PutOption struct {
+	ModRevision int64 // 大于0时要求key当前的修改版本与其一致
+	TTL         int64 // 大于0时为key创建一个该秒数的新租约
+	KeepLease   bool  // TTL为0时保留key当前绑定的租约
+}
+
+// ListOption 获取目录列表的参数
+type ListOption struct {
+	Rev    int64  // 读取的版本,0为最新
+	Limit  int    // 每页数量,0为不限制
+	Cursor string // 上一页返回的游标
+	Sort   string // 排序字段 key,version,mod_revision, 前面加 - 为倒序
+}
+
+// ListPage 一页目录列表
+type ListPage struct {
+	List     []*Node `json:"list"`
+	Cursor   string  `json:"cursor"`          // 下一页的游标,为空表示没有下一页
+	Revision int64   `json:"revision,string"` // 读取时的版本,翻页时带上可保证数据一致
+	Count    int64   `json:"count,string"`    // 目录下所有层级key的数量
+}
+
+// SearchOption 搜索key的参数
+type SearchOption struct {
+	Prefix     string // 搜索的目录,包括目录本身以及其下的key
+	Name       string // 匹配key的表达式,为空不匹配
+	NameRegex  bool   // Name是否为正则,否则为通配符(同时匹配完整路径和最后一级名称)
+	Value      string // 匹配值的表达式,为空不匹配
+	ValueRegex bool   // Value是否为正则,否则为包含的子串
+	Rev        int64  // 读取的版本,0为最新
+	Limit      int    // 最多返回的数量
+	Cursor     string // 上一次返回的游标
+	Budget     int    // 本次最多扫描的key数量,防止大集群搜索过久
+}
+
+// SearchResult 搜索结果
+type SearchResult struct {
+	List     []*Node `json:"list"`
+	Cursor   string  `json:"cursor"`          // 继续搜索的游标,为空表示已搜索完
+	Scanned  int     `json:"scanned"`         // 本次扫描的key数量
+	Revision int64   `json:"revision,string"` // 读取时的版本
+}
+
+// CopyOption 复制或移动key的参数
+type CopyOption struct {
+	Conflict string // 目标key已存在时的处理方式,默认为 CONFLICT_FAIL
+	DryRun   bool   // 只返回将要执行的操作,不写入
+}
+
+// CopyItem 一个key的复制
+type CopyItem struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Overwrite bool   `json:"overwrite"` // 目标key已存在
+}
+
+// CopyResult 复制或移动的结果
+type CopyResult struct {
+	Puts      []*CopyItem `json:"puts"`      // 写入的key
+	Skips     []*CopyItem `json:"skips"`     // 目标已存在而跳过的key
+	Deletes   []string    `json:"deletes"`   // 移动时删除的源key
+	Conflicts []string    `json:"conflicts"` // 已存在的目标key
+	Atomic    bool        `json:"atomic"`    // 是否在一个事务中完成
+	Batches   int         `json:"batches"`   // 使用的事务数量
+	DryRun    bool        `json:"dry_run"`
+}
+
+// FormatOption 格式化的参数
+type FormatOption struct {
+	// 为true时值都作为字符串,只按类型提示转换,导出后再导入与原值完全一致
+	// 目录下只有从0开始连续的数字时作为数组
+	Typed bool
+	// key(或以/结尾的前缀)对应的类型 TYPE_STRING 等,前缀匹配时最长的优先
+	Hints map[string]string
+}
+
+// ImportOption 导入文档的参数
+type ImportOption struct {
+	Mode   string            // IMPORT_MERGE 或 IMPORT_REPLACE, 默认合并
+	DryRun bool              // 只返回差异,不写入
+	Hints  map[string]string // 类型提示, TYPE_JSON 类型的key整体保存为json字符串
+}
+
+// ImportChange 导入时一个key的变化
+type ImportChange struct {
+	Key    string `json:"key"`
+	Action string `json:"action"` // create,update,delete
+	Old    string `json:"old"`
+	New    string `json:"new"`
+}
+
+// ImportResult 导入结果
+type ImportResult struct {
+	Changes []*ImportChange `json:"changes"`
+	Atomic  bool            `json:"atomic"`  // 是否在一个事务中完成
+	Batches int             `json:"batches"` // 使用的事务数量
+	DryRun  bool            `json:"dry_run"`
+}
+
+// BackupHeader 备份文件头
+type BackupHeader struct {
+	Format   string `json:"format"`          // 固定为 BACKUP_FORMAT
+	Version  int    `json:"version"`         // 备份文件格式的版本
+	Server   string `json:"server"`          // 备份的etcd服务名
+	Prefix   string `json:"prefix"`          // 备份的key前缀
+	Revision int64  `json:"revision,string"` // 备份时的版本
+	Created  string `json:"created"`         // 备份时间 RFC3339
+}
+
+// BackupKV 备份的一个key
+type BackupKV struct {
+	Key            string `json:"key"`
+	Value          []byte `json:"value"`               // base64编码,兼容非utf8的值
+	Lease          string `json:"lease,omitempty"`     // 原租约ID
+	LeaseTTL       int64  `json:"lease_ttl,omitempty"` // 原租约的授予秒数
+	Version        int64  `json:"version,string"`
+	CreateRevision int64  `json:"create_revision,string"`
+	ModRevision    int64  `json:"mod_revision,string"`
+}
+
+// RestoreOption 恢复备份的参数
+type RestoreOption struct {
+	Prefix   string // 恢复到的前缀,为空时恢复到备份时的前缀
+	Conflict string // 目标key已存在且值不同时的处理方式,默认为 CONFLICT_FAIL
+	DryRun   bool   // 只返回汇总,不写入
+}
+
+// RestoreResult 恢复备份的结果
+type RestoreResult struct {
+	Header    *BackupHeader `json:"header"`
+	Total     int           `json:"total"`     // 备份中key的数量
+	Created   int           `json:"created"`   // 新建的key数量
+	Updated   int           `json:"updated"`   // 覆盖的key数量
+	Skipped   int           `json:"skipped"`   // 已存在而跳过的key数量
+	Unchanged int           `json:"unchanged"` // 值相同无需写入的key数量
+	Leases    int           `json:"leases"`    // 重新创建的租约数量
+	Conflicts []string      `json:"conflicts"` // 已存在且值不同的key
+	Batches   int           `json:"batches"`   // 使用的事务数量
+	DryRun    bool          `json:"dry_run"`
+}
+
+// Lease 租约信息
+type Lease struct {
+	ID         string   `json:"id"`          // 十六进制的租约ID,与etcdctl一致
+	TTL        int64    `json:"ttl"`         // 剩余秒数,已过期为-1
+	GrantedTTL int64    `json:"granted_ttl"` // 创建或续约时的秒数
+	Keys       []string `json:"keys"`        // 绑定的key
+}
+
+// KeyHistory key的一个历史版本
+type KeyHistory struct {
+	Key            string `json:"key"`
+	Value          string `json:"value"`
+	IsDir          bool   `json:"is_dir"`
+	Version        int64  `json:"version,string"`
+	CreateRevision int64  `json:"create_revision,string"`
+	ModRevision    int64  `json:"mod_revision,string"`
+}
+
+func NewKeyHistory(kv *mvccpb.KeyValue) *KeyHistory {
+	return &KeyHistory{
+		Key:            string(kv.Key),
+		Value:          string(kv.Value),
+		IsDir:          string(kv.Value) == DEFAULT_DIR_VALUE,
+		Version:        kv.Version,
+		CreateRevision: kv.CreateRevision,
+		ModRevision:    kv.ModRevision,
+	}
+}
+
+// RollbackResult 回滚结果
+type RollbackResult struct {
+	FromRevision int64    `json:"from_revision,string"` // 回滚前的版本
+	ToRevision   int64    `json:"to_revision,string"`   // 回滚的目标版本
+	Revision     int64    `json:"revision,string"`      // 回滚后的版本
+	Puts         []string `json:"puts"`                 // 重新写入的key
+	Deletes      []string `json:"deletes"`              // 删除的key
+}
+
+// WatchEvent key的变化事件
+type WatchEvent struct {
+	Type      string `json:"type"` // PUT 或 DELETE
+	Key       string `json:"key"`
+	IsDir     bool   `json:"is_dir"`
+	Value     string `json:"value"`
+	PrevValue string `json:"prev_value"`
+	Revision  int64  `json:"revision,string"` // 事件发生时的版本
+	Version   int64  `json:"version,string"`
+}
+
+func NewWatchEvent(ev *clientv3.Event) *WatchEvent {
+	e := &WatchEvent{
+		Type:     ev.Type.String(),
+		Key:      string(ev.Kv.Key),
+		IsDir:    string(ev.Kv.Value) == DEFAULT_DIR_VALUE,
+		Value:    string(ev.Kv.Value),
+		Revision: ev.Kv.ModRevision,
+		Version:  ev.Kv.Version,
 	}
+	if ev.PrevKv != nil {
+		e.PrevValue = string(ev.PrevKv.Value)
+		if string(ev.PrevKv.Value) == DEFAULT_DIR_VALUE {
+			e.IsDir = true
+		}
+	}
+	return e
+}
+
+// SnapshotInfo 快照信息
+type SnapshotInfo struct {
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"` // 整个快照文件的sha256
+}
+
+// SnapshotVerify 快照校验结果
+type SnapshotVerify struct {
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"` // 整个快照文件的sha256
+	HasHash  bool   `json:"has_hash"` // 快照末尾是否有sha256
+	Hash     string `json:"hash"`     // 快照末尾记录的数据部分sha256
+	Valid    bool   `json:"valid"`
+	Msg      string `json:"msg,omitempty"`
+}
+
+// MemberAddResult 添加节点的结果
+type MemberAddResult struct {
+	Member         *etcdserverpb.Member   `json:"member"`
+	Members        []*etcdserverpb.Member `json:"members"`
+	InitialCluster string                 `json:"initial_cluster"` // 新节点启动时的 --initial-cluster 参数
+}
+
+// CompactResult 压缩的结果
+type CompactResult struct {
+	Revision        int64 `json:"revision,string"`         // 压缩到的版本
+	CurrentRevision int64 `json:"current_revision,string"` // 压缩时的最新版本
+}
+
+// DefragProgress 整理节点的进度
+type DefragProgress struct {
+	MemberID     string  `json:"member_id"`
+	Name         string  `json:"name"`
+	Endpoint     string  `json:"endpoint"`
+	Index        int     `json:"index"` // 第几个节点,从1开始
+	Total        int     `json:"total"`
+	Status       string  `json:"status"`
+	DbSizeBefore int64   `json:"db_size_before"`
+	DbSizeAfter  int64   `json:"db_size_after"`
+	Elapsed      float64 `json:"elapsed"` // 耗时,秒
+	Error        string  `json:"error,omitempty"`
+}
+
+// Alarm 告警
+type Alarm struct {
+	MemberID string `json:"member_id"`
+	Alarm    string `json:"alarm"` // NOSPACE 或 CORRUPT
+}
+
+// MoveLeaderResult 转移leader的结果
+type MoveLeaderResult struct {
+	From       string `json:"from"`        // 原leader节点id
+	To         string `json:"to"`          // 目标节点id
+	Leader     string `json:"leader"`      // 转移后的leader节点id
+	LeaderName string `json:"leader_name"` // 转移后的leader节点名称
+}
+
+// ConsistencyResult 节点数据一致性检查的结果
+type ConsistencyResult struct {
+	Revision   int64         `json:"revision,string"` // 计算hash的版本
+	Consistent bool          `json:"consistent"`      // 所有节点都成功获取到hash并且一致
+	Members    []*MemberHash `json:"members"`
+}
+
+// MemberHash 节点在指定版本的hash
+type MemberHash struct {
+	MemberID        string `json:"member_id"`
+	Name            string `json:"name"`
+	Endpoint        string `json:"endpoint"`
+	Hash            uint32 `json:"hash"`
+	CompactRevision int64  `json:"compact_revision,string"`
+	Divergent       bool   `json:"divergent"` // hash与多数节点不一致
+	Error           string `json:"error,omitempty"`
+	ok              bool   // 是否成功获取到hash
+}
+
+// AuthUser etcd用户
+type AuthUser struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+// AuthRole etcd角色
+type AuthRole struct {
+	Name        string            `json:"name"`
+	Permissions []*AuthPermission `json:"permissions"`
+}
+
+// AuthPermission 角色对key范围的权限
+type AuthPermission struct {
+	Key      string `json:"key"`
+	RangeEnd string `json:"range_end"` // 为空时只对key本身有效
+	Prefix   bool   `json:"prefix"`    // 是否为key前缀的权限
+	Perm     string `json:"perm"`      // read, write 或 readwrite
 }
@@ -0,0 +1,130 @@
+package etcdv3
+
+import (
+	"context"
+	"errors"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rollback 将key或整个目录回滚到rev版本时的内容
+// 在一个事务中完成: 重新写入当时存在(包括之后被删除)的key,删除当时不存在的key
+func (c *Etcd3Client) Rollback(key string, rev int64) (*RollbackResult, error) {
+	if rev <= 0 {
+		return nil, errors.New("rev must be greater than 0")
+	}
+	key = strings.TrimRight(key, "/")
+	if key == "" {
+		key = "/"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 当前值,后续读取都以此时的版本为准
+	curResp, err := c.Client.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	curRev := curResp.Header.Revision
+	if rev > curRev {
+		return nil, ErrorFutureRev
+	}
+	oldResp, err := c.Client.Get(ctx, key, clientv3.WithRev(rev))
+	if err != nil {
+		return nil, err
+	}
+	cur := kvsToMap(curResp.Kvs)
+	old := kvsToMap(oldResp.Kvs)
+
+	// 任意一个版本是目录,就按目录回滚
+	isDir := key == "/" || isDirKvs(curResp.Kvs) || isDirKvs(oldResp.Kvs)
+	dir := key
+	if isDir {
+		if key != "/" {
+			dir = key + "/"
+		}
+		resp, err := c.Client.Get(ctx, dir, clientv3.WithPrefix(), clientv3.WithRev(curRev))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range kvsToMap(resp.Kvs) {
+			cur[k] = v
+		}
+		resp, err = c.Client.Get(ctx, dir, clientv3.WithPrefix(), clientv3.WithRev(rev))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range kvsToMap(resp.Kvs) {
+			old[k] = v
+		}
+	}
+
+	ret := &RollbackResult{
+		FromRevision: curRev,
+		ToRevision:   rev,
+		Revision:     curRev,
+		Puts:         make([]string, 0),
+		Deletes:      make([]string, 0),
+	}
+	for k, v := range old {
+		if cv, ok := cur[k]; !ok || cv != v {
+			ret.Puts = append(ret.Puts, k)
+		}
+	}
+	for k := range cur {
+		if _, ok := old[k]; !ok {
+			ret.Deletes = append(ret.Deletes, k)
+		}
+	}
+	sort.Strings(ret.Puts)
+	sort.Strings(ret.Deletes)
+
+	ops := make([]clientv3.Op, 0, len(ret.Puts)+len(ret.Deletes))
+	for _, k := range ret.Puts {
+		ops = append(ops, clientv3.OpPut(k, old[k]))
+	}
+	for _, k := range ret.Deletes {
+		ops = append(ops, clientv3.OpDelete(k))
+	}
+	if len(ops) == 0 { // 内容相同,无需回滚
+		return ret, nil
+	}
+	if len(ops) > MAX_TXN_OPS {
+		return nil, ErrorTxnTooLarge
+	}
+
+	// 读取之后key有修改则放弃回滚
+	cmp := []clientv3.Cmp{
+		clientv3.Compare(clientv3.ModRevision(key), "<", curRev+1),
+	}
+	if isDir {
+		cmp = append(cmp, clientv3.Compare(clientv3.ModRevision(dir), "<", curRev+1).WithPrefix())
+	}
+	txnResp, err := c.Client.Txn(ctx).If(cmp...).Then(ops...).Commit()
+	if err != nil {
+		return nil, err
+	}
+	if !txnResp.Succeeded {
+		return nil, ErrorKeyModified
+	}
+	ret.Revision = txnResp.Header.Revision
+	return ret, nil
+}
+
+// 判断kv列表中的第一个是否为目录
+func isDirKvs(kvs []*mvccpb.KeyValue) bool {
+	return len(kvs) > 0 && string(kvs[0].Value) == DEFAULT_DIR_VALUE
+}
+
+// kv列表转为 key->value 的map
+func kvsToMap(kvs []*mvccpb.KeyValue) map[string]string {
+	m := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		m[string(kv.Key)] = string(kv.Value)
+	}
+	return m
+}
@@ -0,0 +1,191 @@
+package etcdv3
+
+import (
+	"context"
+	"errors"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Search 分批扫描目录本身以及其下的key,按key路径以及值进行匹配
+// 找到Limit个结果或扫描了Budget个key时停止,返回游标用于继续搜索
+func (c *Etcd3Client) Search(opt *SearchOption) (*SearchResult, error) {
+	if opt == nil || (opt.Name == "" && opt.Value == "") {
+		return nil, ErrorSearchEmpty
+	}
+	if opt.Prefix == "" {
+		opt.Prefix = "/"
+	}
+	matchName, err := newNameMatcher(opt.Name, opt.NameRegex)
+	if err != nil {
+		return nil, err
+	}
+	matchValue, err := newValueMatcher(opt.Value, opt.ValueRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := dirRange(opt.Prefix)
+	if opt.Cursor != "" {
+		if opt.Cursor < start || opt.Cursor >= end {
+			return nil, ErrorListCursor
+		}
+		start = opt.Cursor
+	}
+
+	ret := &SearchResult{
+		List:     make([]*Node, 0),
+		Revision: opt.Rev,
+	}
+	matched := make([]*mvccpb.KeyValue, 0)
+scan:
+	for {
+		batch := int64(LIST_SCAN_BATCH)
+		if opt.Budget > 0 && int64(opt.Budget-ret.Scanned) < batch {
+			batch = int64(opt.Budget - ret.Scanned)
+		}
+		getOpts := []clientv3.OpOption{
+			clientv3.WithRange(end),
+			clientv3.WithLimit(batch),
+			clientv3.WithRev(ret.Revision),
+		}
+		if matchValue == nil { // 不匹配值时只取key
+			getOpts = append(getOpts, clientv3.WithKeysOnly())
+		}
+		resp, err := c.searchBatch(start, getOpts)
+		if err != nil {
+			return nil, err
+		}
+		if ret.Revision == 0 { // 之后的批次都读取同一个版本
+			ret.Revision = resp.Header.Revision
+		}
+
+		for _, kv := range resp.Kvs {
+			if opt.Limit > 0 && len(matched) >= opt.Limit {
+				ret.Cursor = string(kv.Key)
+				break scan
+			}
+			ret.Scanned++
+			if underPrefix(opt.Prefix, string(kv.Key)) && matchKv(kv, matchName, matchValue) {
+				matched = append(matched, kv)
+			}
+		}
+		if !resp.More || len(resp.Kvs) == 0 {
+			break
+		}
+		start = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+		if opt.Budget > 0 && ret.Scanned >= opt.Budget {
+			ret.Cursor = start
+			break
+		}
+	}
+
+	// 只取key时没有值,需要读取匹配的key的值才能判断是否为目录
+	if matchValue == nil {
+		var err error
+		matched, err = c.searchValues(matched, ret.Revision)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, kv := range matched {
+		ret.List = append(ret.List, NewNode(parentDir(string(kv.Key)), kv))
+	}
+	return ret, nil
+}
+
+// 在同一个版本读取key的值,每个事务单独设置超时时间
+func (c *Etcd3Client) searchValues(kvs []*mvccpb.KeyValue, rev int64) ([]*mvccpb.KeyValue, error) {
+	ret := make([]*mvccpb.KeyValue, 0, len(kvs))
+	for i := 0; i < len(kvs); i += MAX_TXN_OPS {
+		batch := kvs[i:]
+		if len(batch) > MAX_TXN_OPS {
+			batch = batch[:MAX_TXN_OPS]
+		}
+		ops := make([]clientv3.Op, 0, len(batch))
+		for _, kv := range batch {
+			ops = append(ops, clientv3.OpGet(string(kv.Key), clientv3.WithRev(rev)))
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		txnResp, err := c.Client.Txn(ctx).Then(ops...).Commit()
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range txnResp.Responses {
+			ret = append(ret, r.GetResponseRange().Kvs...)
+		}
+	}
+	return ret, nil
+}
+
+// 每个批次单独设置超时时间
+func (c *Etcd3Client) searchBatch(start string, opts []clientv3.OpOption) (*clientv3.GetResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return c.Client.Get(ctx, start, opts...)
+}
+
+// 判断key是否匹配,目录只匹配名称
+func matchKv(kv *mvccpb.KeyValue, matchName, matchValue func(string) bool) bool {
+	if matchName != nil && !matchName(string(kv.Key)) {
+		return false
+	}
+	if matchValue != nil {
+		value := string(kv.Value)
+		if value == DEFAULT_DIR_VALUE || !matchValue(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// key的匹配函数, 通配符同时匹配完整路径和最后一级名称
+func newNameMatcher(pattern string, isRegex bool) (func(string) bool, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if isRegex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, errors.New("invalid glob pattern")
+	}
+	return func(key string) bool {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+		ok, _ := path.Match(pattern, path.Base(key))
+		return ok
+	}, nil
+}
+
+// 值的匹配函数,非正则时为包含子串
+func newValueMatcher(pattern string, isRegex bool) (func(string) bool, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if isRegex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	}
+	return func(value string) bool {
+		return strings.Contains(value, pattern)
+	}, nil
+}
+
+// key所在的目录,带末尾的 /
+func parentDir(key string) string {
+	return key[:strings.LastIndex(key, "/")+1]
+}
@@ -0,0 +1,76 @@
+package etcdv3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+// etcd的快照为boltdb文件,页大小为512的整数倍,通过Snapshot接口获取时末尾追加了sha256
+const SNAPSHOT_PAGE_SIZE = 512
+
+// Snapshot 获取etcd当前的快照写入w,返回写入的大小和整个文件的sha256
+func (c *Etcd3Client) Snapshot(ctx context.Context, w io.Writer) (*SnapshotInfo, error) {
+	rc, err := c.Client.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(w, h), rc)
+	if err != nil {
+		return nil, err
+	}
+	return &SnapshotInfo{
+		Size:     size,
+		Checksum: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// VerifySnapshot 校验快照文件末尾的sha256与数据内容是否一致
+func VerifySnapshot(r io.Reader) (*SnapshotVerify, error) {
+	h := sha256.New()    // 数据部分的hash
+	file := sha256.New() // 整个文件的hash
+	tail := make([]byte, 0, 2*sha256.Size)
+	buf := make([]byte, 32*1024)
+	var size int64
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			file.Write(buf[:n])
+			size += int64(n)
+			// 最后sha256.Size个字节可能是hash,先保留不计入数据部分
+			tail = append(tail, buf[:n]...)
+			if len(tail) > sha256.Size {
+				h.Write(tail[:len(tail)-sha256.Size])
+				tail = append(tail[:0], tail[len(tail)-sha256.Size:]...)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ret := &SnapshotVerify{
+		Size:     size,
+		Checksum: hex.EncodeToString(file.Sum(nil)),
+	}
+	if size%SNAPSHOT_PAGE_SIZE != sha256.Size {
+		// 直接从数据目录复制的db文件没有hash,无法校验
+		ret.Msg = "snapshot has no integrity hash"
+		return ret, nil
+	}
+	ret.HasHash = true
+	ret.Hash = hex.EncodeToString(tail)
+	ret.Valid = bytes.Equal(h.Sum(nil), tail)
+	if !ret.Valid {
+		ret.Msg = "snapshot integrity hash mismatch"
+	}
+	return ret, nil
+}
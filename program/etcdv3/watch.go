@@ -0,0 +1,47 @@
+package etcdv3
+
+import (
+	"context"
+	"github.com/coreos/etcd/clientv3"
+)
+
+// Watch 监听key本身以及key目录下所有key的变化, rev大于0时从该版本开始(包含该版本)
+// ctx取消时结束监听并关闭返回的chan
+func (c *Etcd3Client) Watch(ctx context.Context, key string, rev int64) clientv3.WatchChan {
+	start, end := dirRange(key)
+	opts := []clientv3.OpOption{
+		clientv3.WithRange(end),
+		clientv3.WithPrevKV(),
+	}
+	if rev > 0 {
+		opts = append(opts, clientv3.WithRev(rev))
+	}
+	// 集群没有leader时断开,避免连着一个被隔离的节点却收不到任何事件
+	wch := c.Client.Watch(clientv3.WithRequireLeader(ctx), start, opts...)
+
+	// 过滤掉范围内不在目录之下的同级key
+	out := make(chan clientv3.WatchResponse)
+	go func() {
+		defer close(out)
+		for wresp := range wch {
+			if len(wresp.Events) > 0 {
+				events := make([]*clientv3.Event, 0, len(wresp.Events))
+				for _, ev := range wresp.Events {
+					if underPrefix(key, string(ev.Kv.Key)) {
+						events = append(events, ev)
+					}
+				}
+				if len(events) == 0 {
+					continue
+				}
+				wresp.Events = events
+			}
+			select {
+			case out <- wresp:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -1,16 +1,20 @@
 package program
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/autotls"
 	"github.com/gin-gonic/gin"
 	"github.com/qiuhoude/etcd-manage/program/config"
 	"github.com/qiuhoude/etcd-manage/program/etcdv3"
+	"github.com/qiuhoude/etcd-manage/program/logger"
+	"github.com/qiuhoude/etcd-manage/program/session"
 	"github.com/qiuhoude/etcd-manage/program/v1"
 	"log"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -28,15 +32,11 @@ func (p *Program) startAPI() {
 		c.Redirect(301, "/ui")
 	})
 
-	// 读取认证列表,添加到gin.Accounts中
-	accounts := gin.Accounts{}
-	if p.cfg.Users != nil {
-		for _, u := range p.cfg.Users {
-			accounts[u.Username] = u.Password
-		}
-	}
-	// v1 api
-	apiV1 := router.Group("/v1", gin.BasicAuth(accounts))
+	// 登录接口不需要认证
+	router.POST("/v1/login", v1.Login)
+
+	// v1 api, 用户可以通过接口修改,所以每次请求时校验
+	apiV1 := router.Group("/v1", p.middlewareAuth())
 	apiV1.Use(p.middlewareEtcd()) // 绑定etcd客户端中间件
 	v1.V1(apiV1)
 
@@ -47,6 +47,10 @@ func (p *Program) startAPI() {
 		Handler:      router,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
+		// /v1/watch 等长连接和下载接口在处理时取消写超时
+		ConnContext: v1.ConnContext,
+		// HTTP/2 无法取消单个请求的写超时,只使用HTTP/1.1
+		TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler)),
 	}
 	log.Println("启动HTTP服务:", addr)
 	// TLS 判断
@@ -90,7 +94,7 @@ func (p *Program) middlewareCors() gin.HandlerFunc {
 			c.Header("Access-Control-Allow-Origin", "*")
 			c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
 			c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, Access-Control-Allow-Origin")
-			c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Content-Type")
+			c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Content-Type, Retry-After, Content-Disposition, X-Snapshot-Id")
 		}
 		//放行所有OPTIONS方法
 		if method == "OPTIONS" {
@@ -99,6 +103,44 @@ func (p *Program) middlewareCors() gin.HandlerFunc {
 	}
 }
 
+// 认证中间件,优先使用登录接口获取的token,兼容Basic认证,密码支持明文以及bcrypt/argon2 hash
+func (p *Program) middlewareAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := ""
+		if token := session.RequestToken(c.Request); token != "" {
+			// 用户被删除后token失效
+			if s := session.Sessions.Get(token); s != nil && p.cfg.GetUserByUsername(s.Username) != nil {
+				username = s.Username
+			}
+		} else if user, password, ok := c.Request.BasicAuth(); ok {
+			// 与登录接口共用登录失败次数限制
+			if wait := session.Logins.Locked(user); wait > 0 {
+				c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+				c.AbortWithStatus(http.StatusTooManyRequests)
+				return
+			}
+			// 短时间内校验通过的用户名和密码不再重复计算hash,用户被删除后不再通过
+			if session.Credentials.Check(user, password) && p.cfg.GetUserByUsername(user) != nil {
+				username = user
+			} else if p.cfg.CheckUser(user, password) != nil {
+				session.Logins.Reset(user)
+				session.Credentials.Add(user, password)
+				username = user
+			} else {
+				session.Logins.Fail(user)
+				logger.Log.Warnw("登录失败", "user", user, "ip", c.ClientIP())
+			}
+		}
+		if username == "" {
+			c.Header("WWW-Authenticate", `Basic realm="Authorization Required"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Set(gin.AuthUserKey, username)
+		c.Next()
+	}
+}
+
 // etcd客户端中间件
 func (p *Program) middlewareEtcd() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -124,6 +166,9 @@ func (p *Program) middlewareEtcd() gin.HandlerFunc {
 
 		// 绑定etcd 连接
 		etcdServerName := c.GetHeader("EtcdServerName")
+		if etcdServerName == "" { // EventSource等无法设置请求头时通过参数传递
+			etcdServerName = c.Query("etcd_server_name")
+		}
 		//fmt.Println("etcdServerName ->", etcdServerName)
 		if strings.EqualFold("", etcdServerName) || strings.EqualFold("null", etcdServerName) {
 			etcdServerName = "default"
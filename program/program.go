@@ -2,8 +2,10 @@ package program
 
 import (
 	"github.com/opentracing/opentracing-go/log"
+	"github.com/qiuhoude/etcd-manage/program/backup"
 	"github.com/qiuhoude/etcd-manage/program/config"
 	"github.com/qiuhoude/etcd-manage/program/logger"
+	"github.com/qiuhoude/etcd-manage/program/session"
 	"net/http"
 	"os/exec"
 	"runtime"
@@ -11,8 +13,9 @@ import (
 
 // Program 主程序
 type Program struct {
-	cfg *config.Config
-	s   *http.Server
+	cfg    *config.Config
+	s      *http.Server
+	backup *backup.Scheduler
 }
 
 // Run 启动程序
@@ -20,6 +23,9 @@ func (p *Program) Run() error {
 	// 启动http服务
 	go p.startAPI()
 
+	// 启动定时备份
+	p.backup.Start()
+
 	// 打开浏览器
 	//go func() {
 	//	time.Sleep(100 * time.Millisecond)
@@ -34,6 +40,9 @@ func (p *Program) Stop() {
 	if p.s != nil {
 		p.s.Close()
 	}
+	if p.backup != nil {
+		p.backup.Stop()
+	}
 }
 
 // New 创建主程序
@@ -50,8 +59,18 @@ func New() (*Program, error) {
 		return nil, err
 	}
 
+	// 登录会话
+	session.Init(cfg.Session)
+
+	// 定时备份
+	scheduler, err := backup.NewScheduler(cfg.Server)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Program{
-		cfg: cfg,
+		cfg:    cfg,
+		backup: scheduler,
 	}, nil
 }
 
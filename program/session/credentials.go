@@ -0,0 +1,98 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+const (
+	CREDENTIAL_TTL       = time.Minute // Basic认证校验结果的缓存时长
+	CREDENTIAL_MAX_CACHE = 10000       // 最多缓存的校验结果数量
+)
+
+// Credentials 缓存一段时间内校验通过的Basic认证,避免每次请求都计算bcrypt/argon2 hash
+// 缓存的key为用户名以及密码的HMAC,不保存密码
+var Credentials = NewCredentialCache(CREDENTIAL_TTL)
+
+// CredentialCache 校验通过的用户名和密码的缓存
+type CredentialCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	secret  []byte
+	entries map[credential]time.Time
+}
+
+type credential struct {
+	username string
+	sum      [sha256.Size]byte
+}
+
+// NewCredentialCache 创建缓存, HMAC的密钥在每次启动时随机生成
+func NewCredentialCache(ttl time.Duration) *CredentialCache {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return &CredentialCache{
+		ttl:     ttl,
+		secret:  secret,
+		entries: make(map[credential]time.Time, 0),
+	}
+}
+
+// Check 用户名和密码是否在缓存时间内校验通过
+func (cc *CredentialCache) Check(username, password string) bool {
+	k := cc.key(username, password)
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	expire, ok := cc.entries[k]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expire) {
+		delete(cc.entries, k)
+		return false
+	}
+	return true
+}
+
+// Add 缓存校验通过的用户名和密码
+func (cc *CredentialCache) Add(username, password string) {
+	k := cc.key(username, password)
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	now := time.Now()
+	if len(cc.entries) >= CREDENTIAL_MAX_CACHE {
+		for ek, expire := range cc.entries {
+			if now.After(expire) {
+				delete(cc.entries, ek)
+			}
+		}
+		if len(cc.entries) >= CREDENTIAL_MAX_CACHE { // 都未过期时不再缓存
+			return
+		}
+	}
+	cc.entries[k] = now.Add(cc.ttl)
+}
+
+// Forget 用户或密码修改后清除该用户的缓存
+func (cc *CredentialCache) Forget(username string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for k := range cc.entries {
+		if k.username == username {
+			delete(cc.entries, k)
+		}
+	}
+}
+
+func (cc *CredentialCache) key(username, password string) credential {
+	mac := hmac.New(sha256.New, cc.secret)
+	mac.Write([]byte(username))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	k := credential{username: username}
+	copy(k.sum[:], mac.Sum(nil))
+	return k
+}
@@ -0,0 +1,81 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// 超过此数量时清理过期的失败记录
+const LIMITER_PURGE_SIZE = 10000
+
+// Limiter 按用户名限制登录失败次数,连续失败max次后锁定lock时长
+type Limiter struct {
+	mu    sync.Mutex
+	max   int
+	lock  time.Duration
+	users map[string]*failure
+}
+
+type failure struct {
+	count int
+	last  time.Time
+}
+
+// NewLimiter 创建登录失败限制, max小于等于0时不限制
+func NewLimiter(max int, lock time.Duration) *Limiter {
+	return &Limiter{
+		max:   max,
+		lock:  lock,
+		users: make(map[string]*failure, 0),
+	}
+}
+
+// Locked 返回用户剩余的锁定时长,未锁定返回0
+func (l *Limiter) Locked(username string) time.Duration {
+	if l.max <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, ok := l.users[username]
+	if !ok || f.count < l.max {
+		return 0
+	}
+	wait := f.last.Add(l.lock).Sub(time.Now())
+	if wait <= 0 {
+		delete(l.users, username)
+		return 0
+	}
+	return wait
+}
+
+// Fail 记录一次登录失败,距上次失败超过锁定时长时重新计数
+func (l *Limiter) Fail(username string) {
+	if l.max <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if len(l.users) >= LIMITER_PURGE_SIZE {
+		for k, v := range l.users {
+			if now.Sub(v.last) > l.lock {
+				delete(l.users, k)
+			}
+		}
+	}
+	f, ok := l.users[username]
+	if !ok || now.Sub(f.last) > l.lock {
+		f = new(failure)
+		l.users[username] = f
+	}
+	f.count++
+	f.last = now
+}
+
+// Reset 登录成功后清除失败记录
+func (l *Limiter) Reset(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.users, username)
+}
@@ -0,0 +1,167 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/qiuhoude/etcd-manage/program/config"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_EXPIRE       = 120 * time.Minute // 默认token有效期
+	DEFAULT_MAX_FAILURES = 5                 // 默认连续登录失败次数
+	DEFAULT_LOCK_TIME    = 15 * time.Minute  // 默认锁定时长
+
+	TOKEN_QUERY = "access_token" // EventSource等无法设置请求头时通过参数传递token
+)
+
+// ErrorSession token不存在或已过期
+var ErrorSession = errors.New("session is invalid or expired")
+
+var (
+	// Sessions 登录会话,保存在内存中,重启后需要重新登录
+	Sessions = NewStore(DEFAULT_EXPIRE)
+	// Logins 每个用户的登录失败次数限制
+	Logins = NewLimiter(DEFAULT_MAX_FAILURES, DEFAULT_LOCK_TIME)
+)
+
+// Init 根据配置初始化登录会话, cfg为nil时使用默认配置
+func Init(cfg *config.Session) {
+	expire, maxFailures, lockTime := DEFAULT_EXPIRE, DEFAULT_MAX_FAILURES, DEFAULT_LOCK_TIME
+	if cfg != nil {
+		if cfg.Expire > 0 {
+			expire = time.Duration(cfg.Expire) * time.Minute
+		}
+		if cfg.MaxFailures != 0 {
+			maxFailures = cfg.MaxFailures
+		}
+		if cfg.LockTime > 0 {
+			lockTime = time.Duration(cfg.LockTime) * time.Minute
+		}
+	}
+	Sessions = NewStore(expire)
+	Logins = NewLimiter(maxFailures, lockTime)
+}
+
+// Session 登录会话
+type Session struct {
+	Token    string    `json:"token"`
+	Username string    `json:"username"`
+	Expire   time.Time `json:"expire"`
+}
+
+// Store 登录会话存储, token为随机生成的不透明字符串
+type Store struct {
+	mu       sync.Mutex
+	expire   time.Duration
+	sessions map[string]*Session
+}
+
+// NewStore 创建登录会话存储
+func NewStore(expire time.Duration) *Store {
+	return &Store{
+		expire:   expire,
+		sessions: make(map[string]*Session, 0),
+	}
+}
+
+// Create 为用户创建一个新的会话
+func (s *Store) Create(username string) (*Session, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	sess := &Session{
+		Token:    token,
+		Username: username,
+		Expire:   time.Now().Add(s.expire),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purge()
+	s.sessions[token] = sess
+	return sess, nil
+}
+
+// Get 获取未过期的会话,不存在或已过期返回nil
+func (s *Store) Get(token string) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[token]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(sess.Expire) {
+		delete(s.sessions, token)
+		return nil
+	}
+	return sess
+}
+
+// Refresh 使用未过期的token换取新的token,旧token立即失效
+func (s *Store) Refresh(token string) (*Session, error) {
+	sess := s.Get(token)
+	if sess == nil {
+		return nil, ErrorSession
+	}
+	newSess, err := s.Create(sess.Username)
+	if err != nil {
+		return nil, err
+	}
+	s.Revoke(token)
+	return newSess, nil
+}
+
+// Revoke 使token失效
+func (s *Store) Revoke(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, token)
+}
+
+// RevokeUser 使用户的所有token失效,返回失效的数量
+func (s *Store) RevokeUser(username string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for token, sess := range s.sessions {
+		if sess.Username == username {
+			delete(s.sessions, token)
+			n++
+		}
+	}
+	return n
+}
+
+// 删除过期的会话,调用时需要持有锁
+func (s *Store) purge() {
+	now := time.Now()
+	for token, sess := range s.sessions {
+		if now.After(sess.Expire) {
+			delete(s.sessions, token)
+		}
+	}
+}
+
+// 生成随机token
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// RequestToken 从请求头 Authorization: Bearer <token> 或 access_token 参数中获取token
+func RequestToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return r.URL.Query().Get(TOKEN_QUERY)
+}
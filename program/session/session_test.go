@@ -0,0 +1,94 @@
+package session
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	s := NewStore(time.Minute)
+	sess, err := s.Create("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Get(sess.Token); got == nil || got.Username != "admin" {
+		t.Fatalf("Get() = %+v", got)
+	}
+
+	newSess, err := s.Refresh(sess.Token)
+	if err != nil || newSess == nil || newSess.Token == sess.Token {
+		t.Fatalf("Refresh() = %+v, %v", newSess, err)
+	}
+	if s.Get(sess.Token) != nil {
+		t.Fatal("old token is still valid after refresh")
+	}
+
+	s.Create("admin")
+	if n := s.RevokeUser("admin"); n != 2 {
+		t.Fatalf("RevokeUser() = %d, want 2", n)
+	}
+	if s.Get(newSess.Token) != nil {
+		t.Fatal("token is still valid after revoke")
+	}
+
+	s = NewStore(-time.Second) // 创建即过期
+	sess, _ = s.Create("admin")
+	if s.Get(sess.Token) != nil {
+		t.Fatal("expired token is valid")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(2, time.Minute)
+	l.Fail("admin")
+	if l.Locked("admin") != 0 {
+		t.Fatal("locked after 1 failure")
+	}
+	l.Fail("admin")
+	if l.Locked("admin") <= 0 || l.Locked("dev") != 0 {
+		t.Fatal("want only admin locked after 2 failures")
+	}
+	l.Reset("admin")
+	if l.Locked("admin") != 0 {
+		t.Fatal("locked after reset")
+	}
+}
+
+func TestCredentialCache(t *testing.T) {
+	cc := NewCredentialCache(time.Minute)
+	if cc.Check("admin", "123456") {
+		t.Fatal("hit before add")
+	}
+	cc.Add("admin", "123456")
+	cc.Add("dev", "123456")
+	if !cc.Check("admin", "123456") || cc.Check("admin", "654321") || cc.Check("admin1", "23456") {
+		t.Fatal("want hit only for the cached username and password")
+	}
+	cc.Forget("admin")
+	if cc.Check("admin", "123456") || !cc.Check("dev", "123456") {
+		t.Fatal("want only admin forgotten")
+	}
+
+	cc = NewCredentialCache(-time.Second)
+	cc.Add("admin", "123456")
+	if cc.Check("admin", "123456") {
+		t.Fatal("hit after expire")
+	}
+}
+
+func TestRequestToken(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/watch?access_token=b", nil)
+	if got := RequestToken(r); got != "b" {
+		t.Fatalf("RequestToken() = %q, want b", got)
+	}
+	r.Header.Set("Authorization", "Bearer a")
+	if got := RequestToken(r); got != "a" {
+		t.Fatalf("RequestToken() = %q, want a", got)
+	}
+	r.Header.Set("Authorization", "Basic YTpi")
+	r.URL.RawQuery = ""
+	if got := RequestToken(r); got != "" {
+		t.Fatalf("RequestToken() = %q, want empty", got)
+	}
+}
@@ -0,0 +1,164 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/qiuhoude/etcd-manage/program/etcdv3"
+	"github.com/qiuhoude/etcd-manage/program/logger"
+	"net/http"
+)
+
+// 获取etcd用户列表
+func getEtcdAuthUsers(c *gin.Context) {
+	doEtcdAuth(c, "获取etcd用户列表", false, func(cli *etcdv3.Etcd3Client, req *AuthReq) (interface{}, error) {
+		return cli.AuthUsers()
+	})
+}
+
+// 创建etcd用户
+func postEtcdAuthUser(c *gin.Context) {
+	doEtcdAuth(c, "创建etcd用户", false, func(cli *etcdv3.Etcd3Client, req *AuthReq) (interface{}, error) {
+		return "ok", cli.AuthUserAdd(req.Name, req.Password)
+	}, "name")
+}
+
+// 删除etcd用户,需要确认
+func delEtcdAuthUser(c *gin.Context) {
+	doEtcdAuth(c, "删除etcd用户", true, func(cli *etcdv3.Etcd3Client, req *AuthReq) (interface{}, error) {
+		return "ok", cli.AuthUserDelete(req.Name)
+	}, "name")
+}
+
+// 修改etcd用户密码
+func putEtcdAuthUserPassword(c *gin.Context) {
+	doEtcdAuth(c, "修改etcd用户密码", false, func(cli *etcdv3.Etcd3Client, req *AuthReq) (interface{}, error) {
+		return "ok", cli.AuthUserPassword(req.Name, req.Password)
+	}, "name")
+}
+
+// 给etcd用户分配角色
+func postEtcdAuthUserRole(c *gin.Context) {
+	doEtcdAuth(c, "分配etcd角色", false, func(cli *etcdv3.Etcd3Client, req *AuthReq) (interface{}, error) {
+		return "ok", cli.AuthUserGrantRole(req.Name, req.Role)
+	}, "name", "role")
+}
+
+// 收回etcd用户的角色
+func delEtcdAuthUserRole(c *gin.Context) {
+	doEtcdAuth(c, "收回etcd角色", false, func(cli *etcdv3.Etcd3Client, req *AuthReq) (interface{}, error) {
+		return "ok", cli.AuthUserRevokeRole(req.Name, req.Role)
+	}, "name", "role")
+}
+
+// 获取etcd角色列表
+func getEtcdAuthRoles(c *gin.Context) {
+	doEtcdAuth(c, "获取etcd角色列表", false, func(cli *etcdv3.Etcd3Client, req *AuthReq) (interface{}, error) {
+		return cli.AuthRoles()
+	})
+}
+
+// 创建etcd角色
+func postEtcdAuthRole(c *gin.Context) {
+	doEtcdAuth(c, "创建etcd角色", false, func(cli *etcdv3.Etcd3Client, req *AuthReq) (interface{}, error) {
+		return "ok", cli.AuthRoleAdd(req.Name)
+	}, "name")
+}
+
+// 删除etcd角色,需要确认
+func delEtcdAuthRole(c *gin.Context) {
+	doEtcdAuth(c, "删除etcd角色", true, func(cli *etcdv3.Etcd3Client, req *AuthReq) (interface{}, error) {
+		return "ok", cli.AuthRoleDelete(req.Name)
+	}, "name")
+}
+
+// 给etcd角色授予key范围的权限
+func postEtcdAuthPermission(c *gin.Context) {
+	doEtcdAuth(c, "授予etcd权限", false, func(cli *etcdv3.Etcd3Client, req *AuthReq) (interface{}, error) {
+		return "ok", cli.AuthRoleGrant(req.Name, req.permission())
+	}, "name", "key", "range_end", "prefix", "perm")
+}
+
+// 收回etcd角色的key范围权限
+func delEtcdAuthPermission(c *gin.Context) {
+	doEtcdAuth(c, "收回etcd权限", false, func(cli *etcdv3.Etcd3Client, req *AuthReq) (interface{}, error) {
+		return "ok", cli.AuthRoleRevoke(req.Name, req.permission())
+	}, "name", "key", "range_end", "prefix")
+}
+
+// 开启etcd认证,需要确认
+func postEtcdAuthEnable(c *gin.Context) {
+	doEtcdAuth(c, "开启etcd认证", true, func(cli *etcdv3.Etcd3Client, req *AuthReq) (interface{}, error) {
+		return "ok", cli.AuthEnable()
+	})
+}
+
+// 关闭etcd认证,需要确认
+func postEtcdAuthDisable(c *gin.Context) {
+	doEtcdAuth(c, "关闭etcd认证", true, func(cli *etcdv3.Etcd3Client, req *AuthReq) (interface{}, error) {
+		return "ok", cli.AuthDisable()
+	})
+}
+
+// 执行etcd认证管理操作并记录日志
+// confirm为true时需要确认, logFields为需要记录到日志的参数名,密码不会被记录
+func doEtcdAuth(c *gin.Context, msg string, confirm bool,
+	fn func(cli *etcdv3.Etcd3Client, req *AuthReq) (interface{}, error), logFields ...string) {
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw(msg+"错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	req := new(AuthReq)
+	if err = c.Bind(req); err != nil {
+		return
+	}
+	cli, err := getEtcdClient(c)
+	if err != nil {
+		return
+	}
+	fields := req.fields()
+	keysAndValues := make([]interface{}, 0, 2*len(logFields))
+	target := make([]string, 0, len(logFields))
+	for _, f := range logFields {
+		keysAndValues = append(keysAndValues, "etcd_"+f, fields[f])
+		target = append(target, fields[f])
+	}
+	if confirm && !requireConfirm(c, msg, target...) {
+		return
+	}
+	ret, err := fn(cli, req)
+	if err != nil {
+		return
+	}
+	go saveLog(c.Copy(), msg, keysAndValues...)
+	c.JSON(http.StatusOK, ret)
+}
+
+// 需要记录日志的参数
+func (req *AuthReq) fields() map[string]string {
+	prefix := "false"
+	if req.Prefix {
+		prefix = "true"
+	}
+	return map[string]string{
+		"name":      req.Name,
+		"role":      req.Role,
+		"key":       req.Key,
+		"range_end": req.RangeEnd,
+		"prefix":    prefix,
+		"perm":      req.Perm,
+	}
+}
+
+func (req *AuthReq) permission() *etcdv3.AuthPermission {
+	return &etcdv3.AuthPermission{
+		Key:      req.Key,
+		RangeEnd: req.RangeEnd,
+		Prefix:   req.Prefix,
+		Perm:     req.Perm,
+	}
+}
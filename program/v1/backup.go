@@ -0,0 +1,164 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/qiuhoude/etcd-manage/program/backup"
+	"github.com/qiuhoude/etcd-manage/program/config"
+	"github.com/qiuhoude/etcd-manage/program/etcdv3"
+	"github.com/qiuhoude/etcd-manage/program/logger"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// 备份服务key前缀下的所有key,以压缩文件下载
+func getEtcdBackup(c *gin.Context) {
+	etcdCli, exists := c.Get("EtcdServer")
+	if exists == false {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": "Etcd client is empty",
+		})
+		return
+	}
+	cli := etcdCli.(*etcdv3.Etcd3Client)
+	etcdCfg := c.MustGet("EtcdServerCfg").(*config.EtcdServer)
+
+	fileName := fmt.Sprintf("%s-%s.etcdbak.gz", etcdCfg.Name, time.Now().Format("20060102150405"))
+	clearWriteDeadline(c)
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	c.Status(http.StatusOK)
+
+	// 已经开始写入文件,出错时只能记录日志,不完整的文件在恢复时会被校验出来
+	header, err := cli.Backup(c.Writer, etcdCfg.Name, etcdCfg.KeyPrefix)
+	if err != nil {
+		logger.Log.Errorw("备份错误", "err", err)
+		return
+	}
+	go saveLog(c.Copy(), "备份", "prefix", header.Prefix, "revision", header.Revision)
+}
+
+// 上传备份文件进行恢复
+// 备份文件可以通过表单文件字段 file 上传,也可以直接作为请求body
+func postEtcdRestore(c *gin.Context) {
+	opt := &etcdv3.RestoreOption{
+		Prefix:   c.Query("prefix"),
+		Conflict: c.Query("conflict"),
+		DryRun:   c.Query("dry_run") == "true",
+	}
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("恢复备份错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	etcdCli, exists := c.Get("EtcdServer")
+	if exists == false {
+		err = errors.New("Etcd client is empty")
+		return
+	}
+	cli := etcdCli.(*etcdv3.Etcd3Client)
+
+	// 上传和写入大量key都可能超过服务的读写超时
+	clearDeadlines(c)
+	var body io.Reader = c.Request.Body
+	if file, _, ferr := c.Request.FormFile("file"); ferr == nil {
+		defer file.Close()
+		body = file
+	}
+	header, list, err := etcdv3.ReadBackup(body)
+	if err != nil {
+		return
+	}
+
+	// 只能恢复到服务配置的key前缀之下
+	prefix := header.Prefix
+	if opt.Prefix != "" {
+		if opt.Prefix, err = scopedKey(c, opt.Prefix, false); err != nil {
+			return
+		}
+		prefix = opt.Prefix
+	}
+	if !inKeyPrefix(getKeyPrefix(c), prefix) || !allowKey(c, config.ACTION_WRITE, prefix) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"msg": "无权限访问",
+		})
+		return
+	}
+
+	ret, err := cli.Restore(header, list, opt)
+	if err == etcdv3.ErrorKeyExists { // 返回冲突的key
+		c.JSON(http.StatusConflict, gin.H{
+			"msg":       err.Error(),
+			"conflicts": ret.Conflicts,
+		})
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	if !opt.DryRun {
+		go saveLog(c.Copy(), "恢复备份", "prefix", prefix, "from_server", header.Server,
+			"from_revision", header.Revision, "conflict", opt.Conflict)
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// 获取定时备份文件列表
+func getEtcdBackupList(c *gin.Context) {
+	etcdCfgIn, exists := c.Get("EtcdServerCfg")
+	if exists == false {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": "Etcd server is empty",
+		})
+		return
+	}
+	etcdCfg := etcdCfgIn.(*config.EtcdServer)
+	list, err := backup.List(etcdCfg)
+	if err != nil {
+		logger.Log.Errorw("获取备份文件列表错误", "err", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": err.Error(),
+		})
+		return
+	}
+	go saveLog(c.Copy(), "获取备份文件列表")
+	c.JSON(http.StatusOK, list)
+}
+
+// 下载定时备份文件
+func getEtcdBackupFile(c *gin.Context) {
+	etcdCfgIn, exists := c.Get("EtcdServerCfg")
+	if exists == false {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": "Etcd server is empty",
+		})
+		return
+	}
+	etcdCfg := etcdCfgIn.(*config.EtcdServer)
+	name := c.Query("name")
+	fileName, err := backup.Path(etcdCfg, name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": err.Error(),
+		})
+		return
+	}
+	if _, err = os.Stat(fileName); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"msg": "备份文件不存在",
+		})
+		return
+	}
+	go saveLog(c.Copy(), "下载备份文件", "file", name)
+	clearWriteDeadline(c)
+	c.FileAttachment(fileName, name)
+}
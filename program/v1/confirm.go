@@ -0,0 +1,77 @@
+package v1
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"github.com/qiuhoude/etcd-manage/program/config"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 确认token的有效期
+const confirmTokenTTL = 2 * time.Minute
+
+// 危险操作的确认token,只能使用一次,并且只能用于同一个用户对同一个服务的同一个操作
+var confirmTokens = struct {
+	sync.Mutex
+	m map[string]*confirmToken
+}{m: make(map[string]*confirmToken)}
+
+type confirmToken struct {
+	op     string
+	expire time.Time
+}
+
+// 需要确认的操作,请求参数 confirm 带有有效的确认token时返回true
+// 否则返回 428 和新的确认token,客户端确认后带上 confirm 参数再次请求
+func requireConfirm(c *gin.Context, action string, target ...string) bool {
+	op := confirmOp(c, action, target)
+	now := time.Now()
+
+	confirmTokens.Lock()
+	defer confirmTokens.Unlock()
+	for k, v := range confirmTokens.m { // 清理过期的token
+		if now.After(v.expire) {
+			delete(confirmTokens.m, k)
+		}
+	}
+	if token := c.Query("confirm"); token != "" {
+		t, ok := confirmTokens.m[token]
+		if ok && t.op == op {
+			delete(confirmTokens.m, token)
+			return true
+		}
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+		})
+		return false
+	}
+	token := hex.EncodeToString(buf)
+	expire := now.Add(confirmTokenTTL)
+	confirmTokens.m[token] = &confirmToken{op: op, expire: expire}
+	c.JSON(http.StatusPreconditionRequired, gin.H{
+		"msg":     "操作需要确认,请带上 confirm 参数再次请求",
+		"action":  action,
+		"target":  strings.Join(target, " "),
+		"confirm": token,
+		"expire":  expire.Unix(),
+	})
+	return false
+}
+
+// 确认token绑定的操作: 用户 服务 操作 目标
+func confirmOp(c *gin.Context, action string, target []string) string {
+	user := c.GetString(gin.AuthUserKey)
+	server := ""
+	if etcdCfg, exists := c.Get("EtcdServerCfg"); exists {
+		server = etcdCfg.(*config.EtcdServer).Name
+	}
+	return strings.Join(append([]string{user, server, action}, target...), "\x00")
+}
@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/qiuhoude/etcd-manage/program/etcdv3"
+	"github.com/qiuhoude/etcd-manage/program/logger"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// 导入文档的最大字节数
+const maxImportSize = 10 << 20
+
+// 导入json,yaml或toml文档到指定前缀下
+// 文档可以通过表单文件字段 file 上传,也可以直接作为请求body
+func postEtcdKeyImport(c *gin.Context) {
+	format := c.Query("format")
+	mode := c.Query("mode")
+	dryRun := c.Query("dry_run") == "true"
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("导入key错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+	key, err := scopedKey(c, c.Query("key"), false)
+	if err != nil {
+		return
+	}
+
+	etcdCli, exists := c.Get("EtcdServer")
+	if exists == false {
+		err = errors.New("Etcd client is empty")
+		return
+	}
+	cli := etcdCli.(*etcdv3.Etcd3Client)
+
+	// 读取文档
+	var body io.Reader = c.Request.Body
+	if file, header, ferr := c.Request.FormFile("file"); ferr == nil {
+		defer file.Close()
+		body = file
+		if format == "" { // 未指定格式时根据文件后缀判断
+			format = strings.TrimPrefix(path.Ext(header.Filename), ".")
+		}
+	}
+	if format == "yml" {
+		format = "yaml"
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(body, maxImportSize+1))
+	if err != nil {
+		return
+	}
+	if len(data) > maxImportSize {
+		err = errors.New("文档过大")
+		return
+	}
+
+	doc, err := etcdv3.ParseDocument(format, data)
+	if err != nil {
+		return
+	}
+	ret, err := cli.Import(key, doc, &etcdv3.ImportOption{
+		Mode:   mode,
+		DryRun: dryRun,
+		Hints:  getTypeHints(c),
+	})
+	if err != nil {
+		return
+	}
+	if !dryRun {
+		go saveLog(c.Copy(), "导入key", "key", key, "mode", mode, "changes", len(ret.Changes))
+	}
+	c.JSON(http.StatusOK, ret)
+}
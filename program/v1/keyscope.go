@@ -0,0 +1,111 @@
+package v1
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+var (
+	errKeyEmpty = errors.New("key不能为空")
+	errKeyPath  = errors.New("key不能包含 . 或 .. 路径")
+	errKeyScope = errors.New("无权限访问")
+)
+
+// 规范化key: 合并重复的/,去掉结尾的/,不允许 . 和 .. 路径
+func normalizeKey(key string) (string, error) {
+	if key == "" {
+		return "", errKeyEmpty
+	}
+	parts := strings.Split(key, "/")
+	list := make([]string, 0, len(parts))
+	for i, p := range parts {
+		if p == "." || p == ".." {
+			return "", errKeyPath
+		}
+		if p == "" && i > 0 { // 保留开头的/
+			continue
+		}
+		list = append(list, p)
+	}
+	key = strings.Join(list, "/")
+	if key == "" {
+		key = "/"
+	}
+	return key, nil
+}
+
+// key是否在key前缀之下,按路径判断, /app 之下不包括 /apple
+func inKeyPrefix(prefix, key string) bool {
+	prefix, err := normalizeKey(prefix)
+	if err != nil { // 没有配置前缀
+		return true
+	}
+	if prefix == "/" {
+		return strings.HasPrefix(key, "/")
+	}
+	return key == prefix || strings.HasPrefix(key, prefix+"/")
+}
+
+// 按前缀读取目录下的key时使用的目录形式,带结尾的/,避免 /app 匹配到 /apple
+func dirKey(key string) string {
+	if strings.HasSuffix(key, "/") {
+		return key
+	}
+	return key + "/"
+}
+
+// 是否以相对于服务key前缀的路径显示和传递key
+func isRelativeKey(c *gin.Context) bool {
+	return c.Query("relative") == "true"
+}
+
+// 获取请求中的key,返回规范化后的完整key
+// 相对模式下key为相对于服务key前缀的路径, optional为true时key为空表示服务的key前缀
+// key不在服务的key前缀之下时返回 errKeyScope
+func scopedKey(c *gin.Context, key string, optional bool) (string, error) {
+	keyPrefix := getKeyPrefix(c)
+	if key == "" && optional {
+		key = keyPrefix
+	} else if key != "" && isRelativeKey(c) {
+		key = strings.TrimSuffix(keyPrefix, "/") + "/" + strings.TrimPrefix(key, "/")
+	}
+	key, err := normalizeKey(key)
+	if err != nil {
+		return "", err
+	}
+	if !inKeyPrefix(keyPrefix, key) {
+		return "", errKeyScope
+	}
+	return key, nil
+}
+
+// 相对模式下把完整key转为相对于服务key前缀的路径
+func displayKey(c *gin.Context, key string) string {
+	if !isRelativeKey(c) {
+		return key
+	}
+	keyPrefix, err := normalizeKey(getKeyPrefix(c))
+	if err != nil || keyPrefix == "/" {
+		return key
+	}
+	if rel := strings.TrimPrefix(key, keyPrefix); rel != key && (rel == "" || rel[0] == '/') {
+		if rel == "" {
+			rel = "/"
+		}
+		return rel
+	}
+	return key
+}
+
+// 返回key错误,超出服务key前缀时为403
+func keyError(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+	if err == errKeyScope {
+		status = http.StatusForbidden
+	}
+	c.AbortWithStatusJSON(status, gin.H{
+		"msg": err.Error(),
+	})
+}
@@ -0,0 +1,186 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/qiuhoude/etcd-manage/program/config"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestNormalizeKey(t *testing.T) {
+	tests := []struct {
+		key, want string
+		err       error
+	}{
+		{"/app/a", "/app/a", nil},
+		{"//app//a", "/app/a", nil},
+		{"/app/", "/app", nil},
+		{"/", "/", nil},
+		{"//", "/", nil},
+		{"app/a", "app/a", nil},
+		{"", "", errKeyEmpty},
+		{"/app/./a", "", errKeyPath},
+		{"/app/../apple", "", errKeyPath},
+		{"..", "", errKeyPath},
+	}
+	for _, v := range tests {
+		got, err := normalizeKey(v.key)
+		if got != v.want || err != v.err {
+			t.Fatalf("normalizeKey(%q) = %q, %v, want %q, %v", v.key, got, err, v.want, v.err)
+		}
+	}
+}
+
+func TestInKeyPrefix(t *testing.T) {
+	tests := []struct {
+		prefix, key string
+		want        bool
+	}{
+		{"/app", "/app", true},
+		{"/app", "/app/a", true},
+		{"/app/", "/app/a", true},
+		{"/app", "/apple", false},
+		{"/app", "/apple/a", false},
+		{"/app", "/", false},
+		{"/", "/apple", true},
+		{"/", "app", false},
+		{"", "/apple", true}, // 没有配置前缀
+	}
+	for _, v := range tests {
+		if got := inKeyPrefix(v.prefix, v.key); got != v.want {
+			t.Fatalf("inKeyPrefix(%q, %q) = %v, want %v", v.prefix, v.key, got, v.want)
+		}
+	}
+}
+
+func TestScopedKey(t *testing.T) {
+	tests := []struct {
+		prefix, query, key string
+		optional           bool
+		want               string
+		err                error
+	}{
+		{"/app", "", "/app/a", false, "/app/a", nil},
+		{"/app", "", "//app//a/", false, "/app/a", nil},
+		{"/app/", "", "/app", false, "/app", nil},
+		{"/app", "", "", true, "/app", nil},
+		{"/app", "", "", false, "", errKeyEmpty},
+		{"/app", "", "/apple/a", false, "", errKeyScope},
+		{"/app", "", "/app/../apple", false, "", errKeyPath},
+		{"/app", "", "/app/./a", false, "", errKeyPath},
+		{"/app", "relative=true", "a/b", false, "/app/a/b", nil},
+		{"/app", "relative=true", "/", false, "/app", nil},
+		{"/app", "relative=true", "../apple/a", false, "", errKeyPath},
+		{"/app", "relative=true", "", true, "/app", nil},
+		{"/", "", "/apple", false, "/apple", nil},
+		{"/", "relative=true", "apple", false, "/apple", nil},
+	}
+	for _, v := range tests {
+		c := testContext(v.prefix, v.query)
+		got, err := scopedKey(c, v.key, v.optional)
+		if got != v.want || err != v.err {
+			t.Fatalf("scopedKey(%q, %q, %q, %v) = %q, %v, want %q, %v",
+				v.prefix, v.query, v.key, v.optional, got, err, v.want, v.err)
+		}
+	}
+}
+
+func TestDisplayKey(t *testing.T) {
+	tests := []struct {
+		prefix, query, key, want string
+	}{
+		{"/app", "relative=true", "/app/a", "/a"},
+		{"/app", "relative=true", "/app", "/"},
+		{"/app/", "relative=true", "/app/a", "/a"},
+		{"/app", "relative=true", "/apple", "/apple"},
+		{"/app", "", "/app/a", "/app/a"},
+		{"/", "relative=true", "/app/a", "/app/a"},
+	}
+	for _, v := range tests {
+		if got := displayKey(testContext(v.prefix, v.query), v.key); got != v.want {
+			t.Fatalf("displayKey(%q, %q, %q) = %q, want %q", v.prefix, v.query, v.key, got, v.want)
+		}
+	}
+}
+
+func TestMiddlewarePermission(t *testing.T) {
+	dir, err := ioutil.TempDir("", "permission")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfgFile := filepath.Join(dir, "cfg.toml")
+	err = ioutil.WriteFile(cfgFile, []byte(`users_file = "`+filepath.Join(dir, "users.toml")+`"
+
+[[role]]
+name = "dev"
+[[role.rule]]
+prefix = "/app/dev"
+actions = ["read"]
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = config.LoadConfig(cfgFile); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	setServer := func(c *gin.Context) {
+		c.Set("EtcdServerCfg", &config.EtcdServer{Name: "default", KeyPrefix: "/app"})
+		c.Set("userRole", c.GetHeader("Role"))
+	}
+	ok := func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	}
+	router.GET("/v1/key", setServer, middlewarePermission, ok)
+	router.GET("/v1/search", setServer, middlewarePermission, ok)
+	router.GET("/v1/unlisted", setServer, middlewarePermission, ok)
+
+	tests := []struct {
+		role, url string
+		want      int
+	}{
+		{"", "/v1/key?key=/app/a", http.StatusOK},
+		{"", "/v1/key?key=//app//a/", http.StatusOK},
+		{"", "/v1/key?key=/app", http.StatusOK},
+		{"", "/v1/key?key=/apple", http.StatusForbidden},
+		{"", "/v1/key?key=/apple/a", http.StatusForbidden},
+		{"", "/v1/key?key=/", http.StatusForbidden},
+		{"", "/v1/key?key=/app/../apple", http.StatusBadRequest},
+		{"", "/v1/key?key=/app/./a", http.StatusBadRequest},
+		{"", "/v1/key", http.StatusBadRequest},
+		{"", "/v1/key?key=a&relative=true", http.StatusOK},
+		{"", "/v1/key?key=../apple&relative=true", http.StatusBadRequest},
+		{"", "/v1/search", http.StatusOK}, // key可选,为服务的key前缀
+		{"dev", "/v1/key?key=/app/dev/a", http.StatusOK},
+		{"dev", "/v1/key?key=/app/devil", http.StatusForbidden},
+		{"dev", "/v1/search", http.StatusForbidden}, // key前缀之下没有读权限
+		{"", "/v1/unlisted", http.StatusForbidden},
+	}
+	for _, v := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", v.url, nil)
+		r.Header.Set("Role", v.role)
+		router.ServeHTTP(w, r)
+		if w.Code != v.want {
+			t.Fatalf("%s %s = %d, want %d", v.role, v.url, w.Code, v.want)
+		}
+	}
+}
+
+// 创建服务key前缀为prefix的请求上下文, query为请求参数
+func testContext(prefix, query string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/v1/key?"+query, nil)
+	c.Set("EtcdServerCfg", &config.EtcdServer{Name: "default", KeyPrefix: prefix})
+	return c
+}
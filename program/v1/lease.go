@@ -0,0 +1,188 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/qiuhoude/etcd-manage/program/config"
+	"github.com/qiuhoude/etcd-manage/program/etcdv3"
+	"github.com/qiuhoude/etcd-manage/program/logger"
+	"net/http"
+)
+
+// 获取租约列表
+func getLeaseList(c *gin.Context) {
+	go saveLog(c.Copy(), "获取租约列表")
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("获取租约列表错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	etcdCli, exists := c.Get("EtcdServer")
+	if exists == false {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": "Etcd client is empty",
+		})
+		return
+	}
+	cli := etcdCli.(*etcdv3.Etcd3Client)
+
+	list, err := cli.Leases()
+	if err != nil {
+		return
+	}
+	// 只返回绑定了当前用户可读的key的租约
+	ret := make([]*etcdv3.Lease, 0, len(list))
+	for _, lease := range list {
+		if filterLeaseKeys(c, lease) {
+			ret = append(ret, lease)
+		}
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// 获取租约信息以及绑定的key
+func getLeaseInfo(c *gin.Context) {
+	go saveLog(c.Copy(), "获取租约信息")
+	id := c.Query("id")
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("获取租约信息错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	etcdCli, exists := c.Get("EtcdServer")
+	if exists == false {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": "Etcd client is empty",
+		})
+		return
+	}
+	cli := etcdCli.(*etcdv3.Etcd3Client)
+
+	lease, err := cli.LeaseInfo(id)
+	if err != nil {
+		return
+	}
+	if !filterLeaseKeys(c, lease) {
+		forbidden(c)
+		return
+	}
+	c.JSON(http.StatusOK, lease)
+}
+
+// 续约一次
+func postLeaseKeepAlive(c *gin.Context) {
+	go saveLog(c.Copy(), "续约", "lease", c.Query("id"))
+	id := c.Query("id")
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("续约错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	etcdCli, exists := c.Get("EtcdServer")
+	if exists == false {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": "Etcd client is empty",
+		})
+		return
+	}
+	cli := etcdCli.(*etcdv3.Etcd3Client)
+
+	// 续约会延长绑定的所有key的有效期
+	lease, err := cli.LeaseInfo(id)
+	if err != nil {
+		return
+	}
+	if !allowLease(c, lease, config.ACTION_WRITE) {
+		forbidden(c)
+		return
+	}
+	lease, err = cli.LeaseKeepAliveOnce(id)
+	if err != nil {
+		return
+	}
+	c.JSON(http.StatusOK, lease)
+}
+
+// 撤销租约
+func delLease(c *gin.Context) {
+	go saveLog(c.Copy(), "撤销租约", "lease", c.Query("id"))
+	id := c.Query("id")
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("撤销租约错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	etcdCli, exists := c.Get("EtcdServer")
+	if exists == false {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": "Etcd client is empty",
+		})
+		return
+	}
+	cli := etcdCli.(*etcdv3.Etcd3Client)
+
+	// 撤销租约会删除绑定的所有key,有key不在服务的key前缀之下或不能删除时拒绝
+	lease, err := cli.LeaseInfo(id)
+	if err != nil {
+		return
+	}
+	if !allowLease(c, lease, config.ACTION_DELETE) {
+		forbidden(c)
+		return
+	}
+	err = cli.LeaseRevoke(id)
+	if err != nil {
+		return
+	}
+	c.JSON(http.StatusOK, "ok")
+}
+
+// 当前用户是否可以对租约绑定的所有key执行操作,没有绑定key时检查服务的key前缀
+func allowLease(c *gin.Context, lease *etcdv3.Lease, action string) bool {
+	if len(lease.Keys) == 0 {
+		return allowKey(c, action, "")
+	}
+	keyPrefix := getKeyPrefix(c)
+	for _, key := range lease.Keys {
+		if !inKeyPrefix(keyPrefix, key) || !allowKey(c, action, key) {
+			return false
+		}
+	}
+	return true
+}
+
+// 只保留租约绑定的key中在服务的key前缀之下且当前用户可读的key
+// 返回false表示绑定的key都不可读,没有绑定key时检查服务的key前缀
+func filterLeaseKeys(c *gin.Context, lease *etcdv3.Lease) bool {
+	if len(lease.Keys) == 0 {
+		return allowKey(c, config.ACTION_READ, "")
+	}
+	keyPrefix := getKeyPrefix(c)
+	keys := make([]string, 0, len(lease.Keys))
+	for _, key := range lease.Keys {
+		if inKeyPrefix(keyPrefix, key) && allowKey(c, config.ACTION_READ, key) {
+			keys = append(keys, displayKey(c, key))
+		}
+	}
+	lease.Keys = keys
+	return len(keys) > 0
+}
@@ -0,0 +1,167 @@
+package v1
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/qiuhoude/etcd-manage/program/etcdv3"
+	"github.com/qiuhoude/etcd-manage/program/logger"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// 压缩历史版本,需要确认
+// rev 压缩到指定版本, keep 保留最近的版本数, physical=true 等待压缩在所有节点上完成
+func postEtcdCompact(c *gin.Context) {
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("压缩历史版本错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	rev, err := getRevQuery(c)
+	if err != nil {
+		return
+	}
+	var keep int64
+	if keepStr := c.Query("keep"); keepStr != "" {
+		keep, err = strconv.ParseInt(keepStr, 10, 64)
+		if err != nil || keep <= 0 {
+			err = errors.New("keep参数错误")
+			return
+		}
+	}
+	// 压缩不可撤销,确认前检查参数
+	if rev > 0 && keep > 0 {
+		err = errors.New("rev和keep只能指定一个")
+		return
+	}
+	if rev == 0 && keep == 0 {
+		err = errors.New("需要指定rev或keep")
+		return
+	}
+	physical := c.Query("physical") == "true"
+	cli, err := getEtcdClient(c)
+	if err != nil {
+		return
+	}
+	if !requireConfirm(c, "compact", "rev="+strconv.FormatInt(rev, 10), "keep="+strconv.FormatInt(keep, 10),
+		"physical="+strconv.FormatBool(physical)) {
+		return
+	}
+	// physical=true 时需要等待所有节点完成,可能超过服务的写超时
+	clearWriteDeadline(c)
+	ret, err := cli.Compact(rev, keep, physical)
+	if err != nil {
+		return
+	}
+	go saveLog(c.Copy(), "压缩历史版本", "revision", ret.Revision,
+		"current_revision", ret.CurrentRevision, "physical", physical)
+	c.JSON(http.StatusOK, ret)
+}
+
+// 依次整理节点,通过 Server-Sent Events 推送每个节点的进度
+// member 指定只整理一个节点,不指定时整理所有节点
+func postEtcdDefrag(c *gin.Context) {
+	var id uint64
+	var err error
+	if member := c.Query("member"); member != "" {
+		if id, err = parseMemberID(member); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+	}
+	cli, err := getEtcdClient(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": err.Error(),
+		})
+		return
+	}
+
+	clearWriteDeadline(c)
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // 关闭nginx缓冲
+	c.Status(http.StatusOK)
+
+	failed := 0
+	err = cli.Defragment(c.Request.Context(), id, func(p *etcdv3.DefragProgress) {
+		c.Stream(func(w io.Writer) bool {
+			writeSSE(w, "", "progress", p)
+			return false
+		})
+		if p.Status == etcdv3.DEFRAG_FAILED {
+			failed++
+		}
+		if p.Status != etcdv3.DEFRAG_RUNNING {
+			go saveLog(c.Copy(), "整理节点", "member_id", p.MemberID, "status", p.Status,
+				"db_size_before", p.DbSizeBefore, "db_size_after", p.DbSizeAfter, "err", p.Error)
+		}
+	})
+	c.Stream(func(w io.Writer) bool {
+		if err != nil {
+			logger.Log.Errorw("整理节点错误", "err", err)
+			writeSSE(w, "", "error", gin.H{"msg": err.Error()})
+		} else {
+			writeSSE(w, "", "done", gin.H{"failed": failed})
+		}
+		return false
+	})
+}
+
+// 获取告警列表
+func getEtcdAlarms(c *gin.Context) {
+	cli, err := getEtcdClient(c)
+	if err == nil {
+		var alarms []*etcdv3.Alarm
+		if alarms, err = cli.Alarms(); err == nil {
+			go saveLog(c.Copy(), "获取告警列表")
+			c.JSON(http.StatusOK, alarms)
+			return
+		}
+	}
+	logger.Log.Errorw("获取告警列表错误", "err", err)
+	c.JSON(http.StatusBadRequest, gin.H{
+		"msg": err.Error(),
+	})
+}
+
+// 解除告警, member 和 alarm 不指定时解除所有告警
+func delEtcdAlarm(c *gin.Context) {
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("解除告警错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	var id uint64
+	member := c.Query("member")
+	if member != "" {
+		if id, err = parseMemberID(member); err != nil {
+			return
+		}
+	}
+	cli, err := getEtcdClient(c)
+	if err != nil {
+		return
+	}
+	alarms, err := cli.AlarmDisarm(id, c.Query("alarm"))
+	if len(alarms) > 0 {
+		go saveLog(c.Copy(), "解除告警", "member", member, "alarm", c.Query("alarm"), "count", len(alarms))
+	}
+	if err != nil {
+		return
+	}
+	c.JSON(http.StatusOK, alarms)
+}
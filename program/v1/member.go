@@ -0,0 +1,223 @@
+package v1
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/qiuhoude/etcd-manage/program/config"
+	"github.com/qiuhoude/etcd-manage/program/etcdv3"
+	"github.com/qiuhoude/etcd-manage/program/logger"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 添加节点
+func postEtcdMember(c *gin.Context) {
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("添加节点错误", "err", err)
+			c.JSON(memberErrorStatus(err), gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	req := new(MemberReq)
+	if err = c.Bind(req); err != nil {
+		return
+	}
+	cli, err := getEtcdClient(c)
+	if err != nil {
+		return
+	}
+	ret, err := cli.MemberAdd(req.Name, req.PeerURLs, req.Learner)
+	if err != nil {
+		return
+	}
+	go saveLog(c.Copy(), "添加节点", "id", formatMemberID(ret.Member.ID), "name", req.Name,
+		"peer_urls", strings.Join(req.PeerURLs, ","), "learner", req.Learner)
+	c.JSON(http.StatusOK, ret)
+}
+
+// 删除节点,需要确认
+func delEtcdMember(c *gin.Context) {
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("删除节点错误", "err", err)
+			c.JSON(memberErrorStatus(err), gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	id, err := parseMemberID(c.Query("id"))
+	if err != nil {
+		return
+	}
+	cli, err := getEtcdClient(c)
+	if err != nil {
+		return
+	}
+	if !requireConfirm(c, "member_remove", formatMemberID(id)) {
+		return
+	}
+	if err = cli.MemberRemove(id); err != nil {
+		return
+	}
+	go saveLog(c.Copy(), "删除节点", "id", formatMemberID(id))
+	c.JSON(http.StatusOK, "ok")
+}
+
+// 修改节点的peer地址,需要确认
+func putEtcdMember(c *gin.Context) {
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("修改节点错误", "err", err)
+			c.JSON(memberErrorStatus(err), gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	req := new(MemberReq)
+	if err = c.Bind(req); err != nil {
+		return
+	}
+	id, err := parseMemberID(req.ID)
+	if err != nil {
+		return
+	}
+	cli, err := getEtcdClient(c)
+	if err != nil {
+		return
+	}
+	peerURLs := strings.Join(req.PeerURLs, ",")
+	if !requireConfirm(c, "member_update", formatMemberID(id), peerURLs) {
+		return
+	}
+	if err = cli.MemberUpdate(id, req.PeerURLs); err != nil {
+		return
+	}
+	go saveLog(c.Copy(), "修改节点", "id", formatMemberID(id), "peer_urls", peerURLs)
+	c.JSON(http.StatusOK, "ok")
+}
+
+// 将learner节点提升为有投票权的节点
+func postEtcdMemberPromote(c *gin.Context) {
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("提升learner节点错误", "err", err)
+			c.JSON(memberErrorStatus(err), gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	req := new(MemberReq)
+	if err = c.Bind(req); err != nil {
+		return
+	}
+	id, err := parseMemberID(req.ID)
+	if err != nil {
+		return
+	}
+	cli, err := getEtcdClient(c)
+	if err != nil {
+		return
+	}
+	if err = cli.MemberPromote(id); err != nil {
+		return
+	}
+	go saveLog(c.Copy(), "提升learner节点", "id", formatMemberID(id))
+	c.JSON(http.StatusOK, "ok")
+}
+
+// 将leader转移到指定节点
+func postEtcdMoveLeader(c *gin.Context) {
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("转移leader错误", "err", err)
+			c.JSON(memberErrorStatus(err), gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	req := new(MemberReq)
+	if err = c.Bind(req); err != nil {
+		return
+	}
+	id, err := parseMemberID(req.ID)
+	if err != nil {
+		return
+	}
+	cli, err := getEtcdClient(c)
+	if err != nil {
+		return
+	}
+	etcdCfg := c.MustGet("EtcdServerCfg").(*config.EtcdServer)
+	ret, err := cli.MoveLeader(etcdCfg, id)
+	if err != nil {
+		return
+	}
+	go saveLog(c.Copy(), "转移leader", "from", ret.From, "to", ret.To, "leader", ret.Leader)
+	c.JSON(http.StatusOK, ret)
+}
+
+// 检查各节点在同一版本上的数据是否一致
+func getEtcdConsistency(c *gin.Context) {
+	cli, err := getEtcdClient(c)
+	if err == nil {
+		var ret *etcdv3.ConsistencyResult
+		if ret, err = cli.Consistency(); err == nil {
+			go saveLog(c.Copy(), "检查数据一致性", "revision", ret.Revision, "consistent", ret.Consistent)
+			if !ret.Consistent {
+				logger.Log.Warnw("节点数据不一致", "revision", ret.Revision)
+			}
+			c.JSON(http.StatusOK, ret)
+			return
+		}
+	}
+	logger.Log.Errorw("检查数据一致性错误", "err", err)
+	c.JSON(http.StatusBadRequest, gin.H{
+		"msg": err.Error(),
+	})
+}
+
+// 获取当前请求的etcd客户端
+func getEtcdClient(c *gin.Context) (*etcdv3.Etcd3Client, error) {
+	etcdCli, exists := c.Get("EtcdServer")
+	if exists == false {
+		return nil, errors.New("Etcd client is empty")
+	}
+	return etcdCli.(*etcdv3.Etcd3Client), nil
+}
+
+// 解析节点id,支持十进制和0x开头的十六进制
+func parseMemberID(s string) (uint64, error) {
+	id, err := strconv.ParseUint(s, 0, 64)
+	if err != nil || id == 0 {
+		return 0, errors.New("invalid member id")
+	}
+	return id, nil
+}
+
+// 节点id以十六进制显示,与etcdctl一致
+func formatMemberID(id uint64) string {
+	return strconv.FormatUint(id, 16)
+}
+
+func memberErrorStatus(err error) int {
+	if err == etcdv3.ErrorMemberNotFound {
+		return http.StatusNotFound
+	}
+	if err == etcdv3.ErrorLearner { // 当前etcd客户端不支持,不是请求错误
+		return http.StatusNotImplemented
+	}
+	return http.StatusBadRequest
+}
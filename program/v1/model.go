@@ -6,9 +6,56 @@ import "github.com/qiuhoude/etcd-manage/program/etcdv3"
 type PostReq struct {
 	*etcdv3.Node
 	EtcdName string `json:"etcd_name"`
+	LeaseTTL int64  `json:"lease_ttl"` // 大于0时为key创建该秒数的新租约
 }
 
-//日志信息
+// RollbackReq 回滚key时的body
+type RollbackReq struct {
+	Key string `json:"key"`
+	Rev int64  `json:"rev"` // 回滚的目标版本
+}
+
+// CopyReq 复制或移动key时的body
+type CopyReq struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Conflict string `json:"conflict"` // 目标已存在时 overwrite,skip,fail, 默认fail
+	DryRun   bool   `json:"dry_run"`  // 只返回将要执行的操作
+}
+
+// MemberReq 添加或修改节点时的body
+type MemberReq struct {
+	ID       string   `json:"id"`        // 节点id,十进制或0x开头的十六进制
+	Name     string   `json:"name"`      // 新节点的名称
+	PeerURLs []string `json:"peer_urls"` // 节点的peer地址
+	Learner  bool     `json:"learner"`   // 是否添加为learner节点
+}
+
+// AuthReq 管理etcd用户和角色时的参数
+type AuthReq struct {
+	Name     string `json:"name" form:"name"`           // 用户名或角色名
+	Password string `json:"password" form:"password"`   // 用户密码
+	Role     string `json:"role" form:"role"`           // 分配或收回的角色
+	Key      string `json:"key" form:"key"`             // 权限的key
+	RangeEnd string `json:"range_end" form:"range_end"` // 权限的范围结尾
+	Prefix   bool   `json:"prefix" form:"prefix"`       // 是否为key前缀的权限
+	Perm     string `json:"perm" form:"perm"`           // read, write 或 readwrite
+}
+
+// UserReq 管理用户时的body
+type UserReq struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// LoginReq 登录时的body
+type LoginReq struct {
+	Username string `json:"username" form:"username" binding:"required"`
+	Password string `json:"password" form:"password" binding:"required"`
+}
+
+// 日志信息
 type LogLine struct {
 	Date  string  `json:"date"`
 	User  string  `json:"user"`
@@ -0,0 +1,142 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/qiuhoude/etcd-manage/program/config"
+	"net/http"
+)
+
+// 接口需要的一项权限
+type permission struct {
+	action   string
+	param    string                    // key所在的query参数名,为空时为服务的key前缀
+	when     func(c *gin.Context) bool // 不为nil时只在返回true时检查
+	optional bool                      // key参数可以为空,为空时为服务的key前缀
+}
+
+// 每个接口需要的权限,值为nil的接口不检查权限,没有列出的接口拒绝访问
+// key在body中的接口绑定参数后在接口中通过 allowKey 检查,避免与绑定时的字段匹配规则不一致
+var routePermissions = map[string][]permission{
+	"GET /v1/members":             {{action: config.ACTION_READ}},
+	"POST /v1/members":            {{action: config.ACTION_ADMIN}},
+	"PUT /v1/members":             {{action: config.ACTION_ADMIN}},
+	"DELETE /v1/members":          {{action: config.ACTION_ADMIN}},
+	"POST /v1/members/promote":    {{action: config.ACTION_ADMIN}},
+	"POST /v1/members/leader":     {{action: config.ACTION_ADMIN}},
+	"GET /v1/members/consistency": {{action: config.ACTION_READ}},
+	"GET /v1/server":              nil,
+	"POST /v1/key":                nil, // 在接口中检查
+	"GET /v1/list":                {{action: config.ACTION_READ, param: "key"}},
+	"GET /v1/key":                 {{action: config.ACTION_READ, param: "key"}},
+	"PUT /v1/key":                 nil, // 在接口中检查
+	"DELETE /v1/key":              {{action: config.ACTION_DELETE, param: "key"}},
+	"GET /v1/key/format":          {{action: config.ACTION_READ, param: "key"}},
+	"GET /v1/key/history":         {{action: config.ACTION_READ, param: "key"}},
+	"POST /v1/key/rollback":       nil, // 在接口中检查
+	"POST /v1/key/move":           nil, // 在接口中检查
+	"POST /v1/key/copy":           nil, // 在接口中检查
+	"POST /v1/key/import": {
+		{action: config.ACTION_WRITE, param: "key"},
+		{action: config.ACTION_DELETE, param: "key", when: func(c *gin.Context) bool {
+			return c.Query("mode") == "replace"
+		}},
+	},
+	"GET /v1/leases":                    nil, // 按租约绑定的key在接口中检查
+	"GET /v1/lease":                     nil, // 按租约绑定的key在接口中检查
+	"POST /v1/lease/keepalive":          nil, // 按租约绑定的key在接口中检查
+	"DELETE /v1/lease":                  nil, // 撤销租约会删除绑定的key,在接口中检查
+	"GET /v1/watch":                     {{action: config.ACTION_READ, param: "key", optional: true}},
+	"GET /v1/search":                    {{action: config.ACTION_READ, param: "key", optional: true}},
+	"GET /v1/backup":                    {{action: config.ACTION_READ}},
+	"POST /v1/restore":                  {{action: config.ACTION_WRITE, param: "prefix", optional: true}}, // 备份文件中的前缀在接口中检查
+	"GET /v1/backups":                   {{action: config.ACTION_READ}},
+	"GET /v1/backups/file":              {{action: config.ACTION_READ}},
+	"GET /v1/snapshot":                  {{action: config.ACTION_ADMIN}},
+	"POST /v1/snapshot/verify":          {{action: config.ACTION_ADMIN}},
+	"GET /v1/snapshot/checksum":         {{action: config.ACTION_ADMIN}},
+	"POST /v1/maintenance/compact":      {{action: config.ACTION_ADMIN}},
+	"POST /v1/maintenance/defrag":       {{action: config.ACTION_ADMIN}},
+	"GET /v1/maintenance/alarms":        {{action: config.ACTION_ADMIN}},
+	"DELETE /v1/maintenance/alarms":     {{action: config.ACTION_ADMIN}},
+	"GET /v1/auth/users":                {{action: config.ACTION_ADMIN}},
+	"POST /v1/auth/users":               {{action: config.ACTION_ADMIN}},
+	"DELETE /v1/auth/users":             {{action: config.ACTION_ADMIN}},
+	"PUT /v1/auth/users/password":       {{action: config.ACTION_ADMIN}},
+	"POST /v1/auth/users/roles":         {{action: config.ACTION_ADMIN}},
+	"DELETE /v1/auth/users/roles":       {{action: config.ACTION_ADMIN}},
+	"GET /v1/auth/roles":                {{action: config.ACTION_ADMIN}},
+	"POST /v1/auth/roles":               {{action: config.ACTION_ADMIN}},
+	"DELETE /v1/auth/roles":             {{action: config.ACTION_ADMIN}},
+	"POST /v1/auth/roles/permissions":   {{action: config.ACTION_ADMIN}},
+	"DELETE /v1/auth/roles/permissions": {{action: config.ACTION_ADMIN}},
+	"POST /v1/auth/enable":              {{action: config.ACTION_ADMIN}},
+	"POST /v1/auth/disable":             {{action: config.ACTION_ADMIN}},
+	"GET /v1/logs":                      nil,
+	"POST /v1/users":                    {{action: config.ACTION_ADMIN}},
+	"PUT /v1/users/password":            {{action: config.ACTION_ADMIN}},
+	"PUT /v1/users/role":                {{action: config.ACTION_ADMIN}},
+	"GET /v1/users":                     nil,
+	"GET /v1/logtypes":                  nil,
+	"POST /v1/session/refresh":          nil,
+	"DELETE /v1/session":                nil,
+}
+
+// 权限检查中间件,在所有v1接口之前按 routePermissions 检查当前用户的角色权限
+func middlewarePermission(c *gin.Context) {
+	perms, ok := routePermissions[c.Request.Method+" "+c.FullPath()]
+	if !ok {
+		forbidden(c)
+		return
+	}
+	for _, p := range perms {
+		if p.when != nil && !p.when(c) {
+			continue
+		}
+		key := ""
+		if p.param != "" { // key必须在服务的key前缀之下
+			var err error
+			key, err = scopedKey(c, c.Query(p.param), p.optional)
+			if err != nil {
+				keyError(c, err)
+				return
+			}
+		}
+		if !allowKey(c, p.action, key) {
+			forbidden(c)
+			return
+		}
+	}
+	c.Next()
+}
+
+// 判断当前用户是否可以对key执行操作, key为空时为服务的key前缀
+func allowKey(c *gin.Context, action, key string) bool {
+	cfg := config.GetCfg()
+	if cfg == nil {
+		return false
+	}
+	if key == "" {
+		key = getKeyPrefix(c)
+	}
+	server := ""
+	if etcdCfg, exists := c.Get("EtcdServerCfg"); exists {
+		server = etcdCfg.(*config.EtcdServer).Name
+	}
+	return cfg.Allow(c.GetString("userRole"), server, key, action)
+}
+
+// 判断当前用户是否可以对key执行所有操作
+func allowKeyActions(c *gin.Context, key string, actions ...string) bool {
+	for _, action := range actions {
+		if !allowKey(c, action, key) {
+			return false
+		}
+	}
+	return true
+}
+
+func forbidden(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"msg": "无权限访问",
+	})
+}
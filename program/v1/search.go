@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/qiuhoude/etcd-manage/program/etcdv3"
+	"github.com/qiuhoude/etcd-manage/program/logger"
+	"net/http"
+	"strconv"
+)
+
+// 按key路径和值搜索key
+func getEtcdKeySearch(c *gin.Context) {
+	go saveLog(c.Copy(), "搜索key")
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("搜索key错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	etcdCli, exists := c.Get("EtcdServer")
+	if exists == false {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": "Etcd client is empty",
+		})
+		return
+	}
+	cli := etcdCli.(*etcdv3.Etcd3Client)
+
+	// 只能搜索服务配置的key前缀之下
+	prefix, err := scopedKey(c, c.Query("key"), true)
+	if err != nil {
+		return
+	}
+
+	rev, err := getRevQuery(c)
+	if err != nil {
+		return
+	}
+	opt := &etcdv3.SearchOption{
+		Prefix:     prefix,
+		Name:       c.Query("name"),
+		NameRegex:  c.Query("name_type") == "regex",
+		Value:      c.Query("value"),
+		ValueRegex: c.Query("value_type") == "regex",
+		Rev:        rev,
+		Cursor:     c.Query("cursor"),
+		Limit:      50,
+		Budget:     10000,
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		opt.Limit, err = strconv.Atoi(limitStr)
+		if err != nil || opt.Limit <= 0 || opt.Limit > 1000 {
+			err = errors.New("limit参数错误")
+			return
+		}
+	}
+	// 单次请求最多扫描10万个key
+	if budgetStr := c.Query("budget"); budgetStr != "" {
+		opt.Budget, err = strconv.Atoi(budgetStr)
+		if err != nil || opt.Budget <= 0 || opt.Budget > 100000 {
+			err = errors.New("budget参数错误")
+			return
+		}
+	}
+
+	ret, err := cli.Search(opt)
+	if err != nil {
+		return
+	}
+	for _, v := range ret.List {
+		v.FullDir = displayKey(c, v.FullDir)
+	}
+	c.JSON(http.StatusOK, ret)
+}
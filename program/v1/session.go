@@ -0,0 +1,94 @@
+package v1
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/qiuhoude/etcd-manage/program/config"
+	"github.com/qiuhoude/etcd-manage/program/logger"
+	"github.com/qiuhoude/etcd-manage/program/session"
+	"net/http"
+	"strconv"
+)
+
+// Login 登录获取token,之后的请求通过 Authorization: Bearer <token> 认证
+func Login(c *gin.Context) {
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("用户登录错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	req := new(LoginReq)
+	if err = c.Bind(req); err != nil {
+		return
+	}
+	cfg := config.GetCfg()
+	if cfg == nil {
+		err = errors.New("config is empty")
+		return
+	}
+
+	// 连续登录失败后锁定一段时间
+	if wait := session.Logins.Locked(req.Username); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"msg": "too many failed logins, please retry later",
+		})
+		return
+	}
+	u := cfg.CheckUser(req.Username, req.Password)
+	if u == nil {
+		session.Logins.Fail(req.Username)
+		logger.Log.Warnw("登录失败", "user", req.Username, "ip", c.ClientIP())
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"msg": "invalid username or password",
+		})
+		return
+	}
+	session.Logins.Reset(req.Username)
+
+	s, err := session.Sessions.Create(u.Username)
+	if err != nil {
+		return
+	}
+	c.Set(gin.AuthUserKey, u.Username)
+	c.Set("userRole", u.Role)
+	go saveLog(c.Copy(), "用户登录")
+	c.JSON(http.StatusOK, s)
+}
+
+// 刷新token,旧token立即失效
+func postSessionRefresh(c *gin.Context) {
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("刷新登录会话错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	s, err := session.Sessions.Refresh(session.RequestToken(c.Request))
+	if err != nil {
+		return
+	}
+	go saveLog(c.Copy(), "刷新登录会话")
+	c.JSON(http.StatusOK, s)
+}
+
+// 退出登录,all=true时使当前用户的所有token失效
+func delSession(c *gin.Context) {
+	user := c.MustGet(gin.AuthUserKey).(string)
+	if c.Query("all") == "true" {
+		session.Sessions.RevokeUser(user)
+	} else if token := session.RequestToken(c.Request); token != "" {
+		session.Sessions.Revoke(token)
+	}
+	go saveLog(c.Copy(), "退出登录", "all", c.Query("all") == "true")
+	c.JSON(http.StatusOK, "ok")
+}
@@ -0,0 +1,196 @@
+package v1
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/qiuhoude/etcd-manage/program/backup"
+	"github.com/qiuhoude/etcd-manage/program/config"
+	"github.com/qiuhoude/etcd-manage/program/etcdv3"
+	"github.com/qiuhoude/etcd-manage/program/logger"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// 最近下载的快照校验值的保留时长
+const SNAPSHOT_CHECKSUM_TTL = time.Hour
+
+// 最近下载的快照校验值,key为用户名和下载id
+// 浏览器无法读取trailer,下载完成后通过 /v1/snapshot/checksum 获取
+var snapshotSums = struct {
+	sync.Mutex
+	m map[string]*snapshotSum
+}{m: make(map[string]*snapshotSum, 0)}
+
+type snapshotSum struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+	Stored   string `json:"stored,omitempty"` // 保存到备份目录的文件名
+	expire   time.Time
+}
+
+// 下载etcd快照,store=true 时同时保存到备份目录,并在快照旁保存sha256校验值文件
+// 快照文件的sha256在下载完成后通过 X-Checksum-Sha256 trailer 返回,
+// 也可以通过 X-Snapshot-Id 或 id 参数指定的下载id从 /v1/snapshot/checksum 获取
+func getEtcdSnapshot(c *gin.Context) {
+	etcdCli, exists := c.Get("EtcdServer")
+	if exists == false {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": "Etcd client is empty",
+		})
+		return
+	}
+	cli := etcdCli.(*etcdv3.Etcd3Client)
+	etcdCfg := c.MustGet("EtcdServerCfg").(*config.EtcdServer)
+
+	id := c.Query("id")
+	if id == "" {
+		b := make([]byte, 16)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	} else if len(id) > 64 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": "id is too long",
+		})
+		return
+	}
+
+	var w io.Writer = c.Writer
+	var file *backup.Writer
+	if c.Query("store") == "true" {
+		var err error
+		file, err = backup.Create(etcdCfg, backup.SNAPSHOT_EXT)
+		if err != nil {
+			logger.Log.Errorw("保存快照错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+		w = io.MultiWriter(c.Writer, file)
+	}
+
+	fileName := fmt.Sprintf("%s-%s%s", etcdCfg.Name, time.Now().Format("20060102150405"), backup.SNAPSHOT_EXT)
+	clearWriteDeadline(c)
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	c.Header("Trailer", "X-Checksum-Sha256")
+	c.Header("X-Snapshot-Id", id)
+	c.Status(http.StatusOK)
+
+	// 已经开始写入文件,出错时只能记录日志,不完整的快照无法通过校验
+	info, err := cli.Snapshot(c.Request.Context(), w)
+	if err != nil {
+		if file != nil {
+			file.Abort()
+		}
+		logger.Log.Errorw("下载快照错误", "err", err)
+		return
+	}
+	c.Writer.Header().Set("X-Checksum-Sha256", info.Checksum)
+
+	stored := ""
+	if file != nil {
+		f, err := file.Commit()
+		if err != nil {
+			logger.Log.Errorw("保存快照错误", "err", err)
+		} else {
+			stored = f.Name
+			if err = backup.WriteChecksum(etcdCfg, f.Name, info.Checksum); err != nil {
+				logger.Log.Errorw("保存快照校验值错误", "err", err)
+			}
+		}
+	}
+	saveSnapshotSum(c.GetString(gin.AuthUserKey), &snapshotSum{
+		ID:       id,
+		Name:     fileName,
+		Size:     info.Size,
+		Checksum: info.Checksum,
+		Stored:   stored,
+	})
+	go saveLog(c.Copy(), "下载快照", "size", info.Size, "checksum", info.Checksum, "file", stored)
+}
+
+// 获取已下载完成的快照的校验值
+func getEtcdSnapshotChecksum(c *gin.Context) {
+	key := c.GetString(gin.AuthUserKey) + "\x00" + c.Query("id")
+	snapshotSums.Lock()
+	sum, ok := snapshotSums.m[key]
+	snapshotSums.Unlock()
+	if !ok || time.Now().After(sum.expire) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"msg": "快照不存在或未下载完成",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, sum)
+}
+
+// 保存快照校验值,同时删除过期的
+func saveSnapshotSum(user string, sum *snapshotSum) {
+	now := time.Now()
+	sum.expire = now.Add(SNAPSHOT_CHECKSUM_TTL)
+	snapshotSums.Lock()
+	defer snapshotSums.Unlock()
+	for k, v := range snapshotSums.m {
+		if now.After(v.expire) {
+			delete(snapshotSums.m, k)
+		}
+	}
+	snapshotSums.m[user+"\x00"+sum.ID] = sum
+}
+
+// 校验快照的完整性
+// 快照可以通过表单文件字段 file 上传,也可以直接作为请求body,或者通过 name 指定备份目录中保存的快照
+func postEtcdSnapshotVerify(c *gin.Context) {
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("校验快照错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	// 上传和校验几百MB的快照会超过服务的读写超时
+	clearDeadlines(c)
+	var body io.Reader = c.Request.Body
+	name := c.Query("name")
+	if name != "" {
+		etcdCfg, exists := c.Get("EtcdServerCfg")
+		if exists == false {
+			err = errors.New("Etcd server is empty")
+			return
+		}
+		var fileName string
+		fileName, err = backup.Path(etcdCfg.(*config.EtcdServer), name)
+		if err != nil {
+			return
+		}
+		var f *os.File
+		f, err = os.Open(fileName)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		body = f
+	} else if file, _, ferr := c.Request.FormFile("file"); ferr == nil {
+		defer file.Close()
+		body = file
+	}
+
+	ret, err := etcdv3.VerifySnapshot(body)
+	if err != nil {
+		return
+	}
+	go saveLog(c.Copy(), "校验快照", "file", name, "checksum", ret.Checksum, "valid", ret.Valid)
+	c.JSON(http.StatusOK, ret)
+}
@@ -0,0 +1,73 @@
+package v1
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/qiuhoude/etcd-manage/program/config"
+	"github.com/qiuhoude/etcd-manage/program/logger"
+	"github.com/qiuhoude/etcd-manage/program/session"
+	"net/http"
+)
+
+// 添加用户
+func postUser(c *gin.Context) {
+	doUser(c, "添加用户", func(cfg *config.Config, req *UserReq) error {
+		return cfg.AddUser(req.Username, req.Password, req.Role)
+	})
+}
+
+// 重置用户密码
+func putUserPassword(c *gin.Context) {
+	doUser(c, "重置用户密码", func(cfg *config.Config, req *UserReq) error {
+		if err := cfg.ResetPassword(req.Username, req.Password); err != nil {
+			return err
+		}
+		// 修改密码后需要重新登录
+		session.Sessions.RevokeUser(req.Username)
+		return nil
+	})
+}
+
+// 修改用户角色
+func putUserRole(c *gin.Context) {
+	doUser(c, "修改用户角色", func(cfg *config.Config, req *UserReq) error {
+		return cfg.SetUserRole(req.Username, req.Role)
+	})
+}
+
+// 修改用户并保存到用户文件,修改后立即生效
+func doUser(c *gin.Context, msg string, fn func(cfg *config.Config, req *UserReq) error) {
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw(msg+"错误", "err", err)
+			status := http.StatusBadRequest
+			if err == config.UserNotFoundErr {
+				status = http.StatusNotFound
+			} else if err == config.UserExistsErr {
+				status = http.StatusConflict
+			}
+			c.JSON(status, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	req := new(UserReq)
+	if err = c.Bind(req); err != nil {
+		return
+	}
+	cfg := config.GetCfg()
+	if cfg == nil {
+		err = errors.New("config is empty")
+		return
+	}
+	if err = fn(cfg, req); err != nil {
+		return
+	}
+	// 用户修改后清除Basic认证的缓存
+	session.Credentials.Forget(req.Username)
+	// 不记录密码
+	go saveLog(c.Copy(), msg, "username", req.Username, "new_role", req.Role)
+	c.JSON(http.StatusOK, "ok")
+}
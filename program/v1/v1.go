@@ -12,6 +12,7 @@ import (
 	"github.com/qiuhoude/etcd-manage/program/logger"
 	"net/http"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 	"time"
@@ -19,17 +20,65 @@ import (
 
 // V1 v1 版接口 路由入口
 func V1(v1 *gin.RouterGroup) {
-	v1.GET("/members", getEtcdMembers)      // 获取节点列表
-	v1.GET("/server", getEtcdServerList)    // 获取etcd服务列表
-	v1.POST("/key", postEtcdKey)            // 添加key
-	v1.GET("/list", getEtcdKeyList)         // 获取etcd key列表
-	v1.GET("/key", getEtcdKeyValue)         // 获取key的值
-	v1.PUT("/key", putEtcdKey)              // 修改key
-	v1.DELETE("/key", delEtcdKey)           // 删除key
-	v1.GET("/key/format", getValueToFormat) // 格式化为json或toml
-	v1.GET("/logs", getLogsList)            // 查询日志
-	v1.GET("/users", getUserList)           // 获取用户列表
-	v1.GET("/logtypes", getLogTypeList)     // 获取日志类型列表
+	v1.Use(middlewarePermission) // 检查角色权限
+
+	v1.GET("/members", getEtcdMembers)                          // 获取节点列表
+	v1.POST("/members", postEtcdMember)                         // 添加节点
+	v1.PUT("/members", putEtcdMember)                           // 修改节点的peer地址
+	v1.DELETE("/members", delEtcdMember)                        // 删除节点
+	v1.POST("/members/promote", postEtcdMemberPromote)          // 提升learner节点
+	v1.POST("/members/leader", postEtcdMoveLeader)              // 转移leader
+	v1.GET("/members/consistency", getEtcdConsistency)          // 检查节点数据一致性
+	v1.GET("/server", getEtcdServerList)                        // 获取etcd服务列表
+	v1.POST("/key", postEtcdKey)                                // 添加key
+	v1.GET("/list", getEtcdKeyList)                             // 获取etcd key列表
+	v1.GET("/key", getEtcdKeyValue)                             // 获取key的值
+	v1.PUT("/key", putEtcdKey)                                  // 修改key
+	v1.DELETE("/key", delEtcdKey)                               // 删除key
+	v1.GET("/key/format", getValueToFormat)                     // 格式化为json,toml,yaml,properties或env
+	v1.GET("/key/history", getEtcdKeyHistory)                   // 获取key的历史版本
+	v1.POST("/key/rollback", postEtcdKeyRollback)               // 回滚key到指定版本
+	v1.POST("/key/move", postEtcdKeyMove)                       // 移动或重命名key
+	v1.POST("/key/copy", postEtcdKeyCopy)                       // 复制key
+	v1.POST("/key/import", postEtcdKeyImport)                   // 导入json,yaml或toml文档
+	v1.GET("/leases", getLeaseList)                             // 获取租约列表
+	v1.GET("/lease", getLeaseInfo)                              // 获取租约信息以及绑定的key
+	v1.POST("/lease/keepalive", postLeaseKeepAlive)             // 续约一次
+	v1.DELETE("/lease", delLease)                               // 撤销租约
+	v1.GET("/watch", getEtcdWatch)                              // 监听key的变化(Server-Sent Events)
+	v1.GET("/search", getEtcdKeySearch)                         // 搜索key
+	v1.GET("/backup", getEtcdBackup)                            // 备份key前缀下的所有key
+	v1.POST("/restore", postEtcdRestore)                        // 上传备份文件进行恢复
+	v1.GET("/backups", getEtcdBackupList)                       // 获取定时备份文件列表
+	v1.GET("/backups/file", getEtcdBackupFile)                  // 下载定时备份文件
+	v1.GET("/snapshot", getEtcdSnapshot)                        // 下载etcd快照
+	v1.POST("/snapshot/verify", postEtcdSnapshotVerify)         // 校验快照完整性
+	v1.GET("/snapshot/checksum", getEtcdSnapshotChecksum)       // 获取已下载快照的校验值
+	v1.POST("/maintenance/compact", postEtcdCompact)            // 压缩历史版本
+	v1.POST("/maintenance/defrag", postEtcdDefrag)              // 依次整理节点
+	v1.GET("/maintenance/alarms", getEtcdAlarms)                // 获取告警列表
+	v1.DELETE("/maintenance/alarms", delEtcdAlarm)              // 解除告警
+	v1.GET("/auth/users", getEtcdAuthUsers)                     // 获取etcd用户列表
+	v1.POST("/auth/users", postEtcdAuthUser)                    // 创建etcd用户
+	v1.DELETE("/auth/users", delEtcdAuthUser)                   // 删除etcd用户
+	v1.PUT("/auth/users/password", putEtcdAuthUserPassword)     // 修改etcd用户密码
+	v1.POST("/auth/users/roles", postEtcdAuthUserRole)          // 给etcd用户分配角色
+	v1.DELETE("/auth/users/roles", delEtcdAuthUserRole)         // 收回etcd用户的角色
+	v1.GET("/auth/roles", getEtcdAuthRoles)                     // 获取etcd角色列表
+	v1.POST("/auth/roles", postEtcdAuthRole)                    // 创建etcd角色
+	v1.DELETE("/auth/roles", delEtcdAuthRole)                   // 删除etcd角色
+	v1.POST("/auth/roles/permissions", postEtcdAuthPermission)  // 授予etcd角色权限
+	v1.DELETE("/auth/roles/permissions", delEtcdAuthPermission) // 收回etcd角色权限
+	v1.POST("/auth/enable", postEtcdAuthEnable)                 // 开启etcd认证
+	v1.POST("/auth/disable", postEtcdAuthDisable)               // 关闭etcd认证
+	v1.GET("/logs", getLogsList)                                // 查询日志
+	v1.POST("/users", postUser)                                 // 添加用户
+	v1.PUT("/users/password", putUserPassword)                  // 重置用户密码
+	v1.PUT("/users/role", putUserRole)                          // 修改用户角色
+	v1.GET("/users", getUserList)                               // 获取用户列表
+	v1.POST("/session/refresh", postSessionRefresh)             // 刷新token
+	v1.DELETE("/session", delSession)                           // 退出登录
+	v1.GET("/logtypes", getLogTypeList)                         // 获取日志类型列表
 
 }
 
@@ -42,6 +91,54 @@ func getLogTypeList(c *gin.Context) {
 		"删除key",
 		"保存key",
 		"获取etcd服务列表",
+		"获取key历史版本",
+		"回滚key",
+		"获取租约列表",
+		"获取租约信息",
+		"续约",
+		"撤销租约",
+		"监听key变化",
+		"搜索key",
+		"移动key",
+		"复制key",
+		"导入key",
+		"备份",
+		"恢复备份",
+		"获取备份文件列表",
+		"下载备份文件",
+		"定时备份",
+		"定时备份失败",
+		"下载快照",
+		"校验快照",
+		"添加节点",
+		"修改节点",
+		"删除节点",
+		"提升learner节点",
+		"压缩历史版本",
+		"整理节点",
+		"获取告警列表",
+		"解除告警",
+		"转移leader",
+		"检查数据一致性",
+		"获取etcd用户列表",
+		"创建etcd用户",
+		"删除etcd用户",
+		"修改etcd用户密码",
+		"分配etcd角色",
+		"收回etcd角色",
+		"获取etcd角色列表",
+		"创建etcd角色",
+		"删除etcd角色",
+		"授予etcd权限",
+		"收回etcd权限",
+		"开启etcd认证",
+		"关闭etcd认证",
+		"添加用户",
+		"重置用户密码",
+		"修改用户角色",
+		"用户登录",
+		"刷新登录会话",
+		"退出登录",
 	})
 }
 
@@ -50,7 +147,7 @@ func getUserList(c *gin.Context) {
 	us := make([]map[string]string, 0)
 	cfg := config.GetCfg()
 	if cfg != nil {
-		for _, v := range cfg.Users {
+		for _, v := range cfg.ListUsers() {
 			us = append(us, map[string]string{
 				"name": v.Username,
 				"role": v.Role,
@@ -153,10 +250,19 @@ func getLogsList(c *gin.Context) {
 	})
 }
 
+// 格式化下载时的文件类型
+var formatContentTypes = map[string]string{
+	"json":       "application/json; charset=utf-8",
+	"toml":       "application/toml; charset=utf-8",
+	"yaml":       "application/x-yaml; charset=utf-8",
+	"properties": "text/x-java-properties; charset=utf-8",
+	"env":        "text/plain; charset=utf-8",
+}
+
+// 格式化显示或下载目录, format 支持 json,toml,yaml,properties,env
 func getValueToFormat(c *gin.Context) {
 	go saveLog(c, "格式化显示key")
 	format := c.Query("format")
-	key := c.Query("key")
 	var err error
 	defer func() {
 		if err != nil {
@@ -166,6 +272,11 @@ func getValueToFormat(c *gin.Context) {
 			})
 		}
 	}()
+	key, err := scopedKey(c, c.Query("key"), false)
+	if err != nil {
+		return
+	}
+	dir := dirKey(key) // 只读取目录下的key
 
 	etcdCli, exists := c.Get("EtcdServer")
 	if exists == false {
@@ -175,31 +286,67 @@ func getValueToFormat(c *gin.Context) {
 		return
 	}
 	cli := etcdCli.(*etcdv3.Etcd3Client)
-	list, err := cli.GetRecursiveValue(key)
+	list, err := cli.GetRecursiveValue(dir)
 	if err != nil {
 		return
 	}
 
+	// 类型保持模式,导出后可以原样导入
+	typed := c.Query("typed") == "true"
+	if typed && format != "json" {
+		err = errors.New("typed参数只支持json格式")
+		return
+	}
+
+	var body string
 	switch format {
 	case "json":
-		resp, err := etcdv3.NodeJsonFormat(key, list)
+		var resp interface{}
+		resp, err = etcdv3.NodeJsonFormatWithOption(dir, list, &etcdv3.FormatOption{
+			Typed: typed,
+			Hints: getTypeHints(c),
+		})
 		if err != nil {
 			return
 		}
 		respJs, _ := json.MarshalIndent(resp, "", "	")
-		c.JSON(http.StatusOK, string(respJs))
-		return
+		body = string(respJs)
 	case "toml":
-
+		body, err = etcdv3.NodeTomlFormat(dir, list)
+	case "yaml":
+		body, err = etcdv3.NodeYamlFormat(dir, list)
+	case "properties":
+		body, err = etcdv3.NodePropertiesFormat(dir, list)
+	case "env":
+		body, err = etcdv3.NodeDotenvFormat(dir, list, c.DefaultQuery("separator", "_"))
 	default:
 		err = errors.New("不支持的格式")
 	}
+	if err != nil {
+		return
+	}
+
+	// 作为文件下载
+	if c.Query("download") != "" {
+		name := path.Base(strings.TrimRight(key, "/"))
+		if name == "" || name == "/" || name == "." {
+			name = "config"
+		}
+		if format == "env" {
+			name = ".env"
+		} else {
+			name += "." + format
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+		c.Data(http.StatusOK, formatContentTypes[format], []byte(body))
+		return
+	}
+	c.JSON(http.StatusOK, body)
 }
 
 // 删除key
 func delEtcdKey(c *gin.Context) {
 	go saveLog(c, "删除key")
-	key := c.Query("key")
 	var err error
 	defer func() {
 		if err != nil {
@@ -209,6 +356,10 @@ func delEtcdKey(c *gin.Context) {
 			})
 		}
 	}()
+	key, err := scopedKey(c, c.Query("key"), false)
+	if err != nil {
+		return
+	}
 	etcdCli, exists := c.Get("EtcdServer")
 	if exists == false {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -232,7 +383,6 @@ func putEtcdKey(c *gin.Context) {
 // 获取key的值
 func getEtcdKeyValue(c *gin.Context) {
 	go saveLog(c.Copy(), "获取key的值")
-	key := c.Query("key")
 	var err error
 	defer func() {
 		if err != nil {
@@ -242,6 +392,10 @@ func getEtcdKeyValue(c *gin.Context) {
 			})
 		}
 	}()
+	key, err := scopedKey(c, c.Query("key"), false)
+	if err != nil {
+		return
+	}
 
 	etcdCli, exists := c.Get("EtcdServer")
 	//fmt.Println("etcdCli,",etcdCli)
@@ -253,18 +407,67 @@ func getEtcdKeyValue(c *gin.Context) {
 	}
 	cli := etcdCli.(*etcdv3.Etcd3Client)
 
-	val, err := cli.Value(key)
+	rev, err := getRevQuery(c)
 	if err != nil {
 		return
 	}
+	val, err := cli.ValueWithRev(key, rev)
+	if err != nil {
+		return
+	}
+	val.FullDir = displayKey(c, val.FullDir)
 	c.JSON(http.StatusOK, val)
 }
 
+// 获取key的历史版本
+func getEtcdKeyHistory(c *gin.Context) {
+	go saveLog(c.Copy(), "获取key历史版本")
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("获取key历史版本错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+	key, err := scopedKey(c, c.Query("key"), false)
+	if err != nil {
+		return
+	}
+
+	etcdCli, exists := c.Get("EtcdServer")
+	if exists == false {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": "Etcd client is empty",
+		})
+		return
+	}
+	cli := etcdCli.(*etcdv3.Etcd3Client)
+
+	rev, err := getRevQuery(c)
+	if err != nil {
+		return
+	}
+	// 默认最多返回50个版本
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	list, err := cli.History(key, rev, limit)
+	if err != nil {
+		return
+	}
+	for _, v := range list {
+		v.Key = displayKey(c, v.Key)
+	}
+	c.JSON(http.StatusOK, list)
+}
+
 // 获取etcd key列表
 func getEtcdKeyList(c *gin.Context) {
 	go saveLog(c.Copy(), "获取etcd服务列表")
 
-	key := c.Query("key")
 	var err error
 	defer func() {
 		if err != nil {
@@ -274,6 +477,10 @@ func getEtcdKeyList(c *gin.Context) {
 			})
 		}
 	}()
+	key, err := scopedKey(c, c.Query("key"), false)
+	if err != nil {
+		return
+	}
 	etcdCli, exists := c.Get("EtcdServer")
 	//fmt.Println("etcdCli,",etcdCli)
 	if exists == false {
@@ -284,19 +491,43 @@ func getEtcdKeyList(c *gin.Context) {
 	}
 	cli := etcdCli.(*etcdv3.Etcd3Client)
 
-	resp, err := cli.List(key)
+	rev, err := getRevQuery(c)
+	if err != nil {
+		return
+	}
+	opt := &etcdv3.ListOption{
+		Rev:    rev,
+		Cursor: c.Query("cursor"),
+		Sort:   c.Query("sort"),
+	}
+	limitStr := c.Query("limit")
+	if limitStr != "" {
+		opt.Limit, err = strconv.Atoi(limitStr)
+		if err != nil || opt.Limit <= 0 || opt.Limit > 1000 {
+			err = errors.New("limit参数错误")
+			return
+		}
+	}
+	page, err := cli.ListPage(key, opt)
 	if err != nil {
 		return
 	}
 
 	list := make([]*etcdv3.Node, 0)
-	for _, v := range resp {
+	for _, v := range page.List {
 		if v.FullDir != "/" {
+			v.FullDir = displayKey(c, v.FullDir)
 			list = append(list, v)
 		}
 	}
+	page.List = list
 
-	c.JSON(http.StatusOK, list)
+	// 没有分页参数时兼容旧版本,只返回列表
+	if limitStr == "" && opt.Cursor == "" && opt.Sort == "" {
+		c.JSON(http.StatusOK, list)
+		return
+	}
+	c.JSON(http.StatusOK, page)
 }
 
 //  添加key
@@ -325,6 +556,13 @@ func doPutEtcdKey(c *gin.Context, isPut bool) {
 		err = errors.New("参数错误")
 		return
 	}
+	if req.FullDir, err = scopedKey(c, req.FullDir, false); err != nil {
+		return
+	}
+	if !allowKey(c, config.ACTION_WRITE, req.FullDir) {
+		forbidden(c)
+		return
+	}
 
 	etcdCli, exists := c.Get("EtcdServer")
 	if exists == false {
@@ -386,8 +624,25 @@ func doPutEtcdKey(c *gin.Context, isPut bool) {
 		} else { // 创建指定目录
 			err = cli.Put(req.FullDir, etcdv3.DEFAULT_DIR_VALUE, true)
 		}
-	} else { // 非目录
-		err = cli.Put(req.FullDir, req.Value, !isPut)
+	} else if isPut { // 修改非目录,带上读取时的版本防止覆盖他人的修改
+		var cur *etcdv3.Node
+		cur, err = cli.PutWithOption(req.FullDir, req.Value, false, &etcdv3.PutOption{
+			ModRevision: req.ModRevision,
+			TTL:         req.LeaseTTL,
+			KeepLease:   true,
+		})
+		if err == etcdv3.ErrorKeyConflict {
+			c.JSON(http.StatusConflict, gin.H{
+				"msg":     err.Error(),
+				"current": cur,
+			})
+			err = nil
+			return
+		}
+	} else { // 添加非目录
+		_, err = cli.PutWithOption(req.FullDir, req.Value, true, &etcdv3.PutOption{
+			TTL: req.LeaseTTL,
+		})
 	}
 	if err != nil {
 		return
@@ -449,7 +704,6 @@ func getEtcdMembers(c *gin.Context) {
 
 	etcdCli, exists := c.Get("EtcdServer")
 	if exists == false {
-		fmt.Println("-->Etcd client is empty")
 		c.JSON(http.StatusBadRequest, gin.H{
 			"msg": "Etcd client is empty",
 		})
@@ -458,15 +712,176 @@ func getEtcdMembers(c *gin.Context) {
 	cli := etcdCli.(*etcdv3.Etcd3Client)
 
 	members, err := cli.Members()
-	fmt.Println("---->>>,len:", len(members))
 	if err != nil {
 		return
 	}
 	c.JSON(http.StatusOK, members)
 }
 
-// 保存日志
-func saveLog(c *gin.Context, msg string) {
+// 回滚key或目录到指定版本
+func postEtcdKeyRollback(c *gin.Context) {
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("回滚key错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	req := new(RollbackReq)
+	err = c.Bind(req) //参数绑定
+	if err != nil {
+		return
+	}
+	if req.Key == "" || req.Rev <= 0 {
+		err = errors.New("参数错误")
+		return
+	}
+	if req.Key, err = scopedKey(c, req.Key, false); err != nil {
+		return
+	}
+	// 回滚目录时会删除之后新增的key
+	if !allowKeyActions(c, req.Key, config.ACTION_WRITE, config.ACTION_DELETE) {
+		forbidden(c)
+		return
+	}
+
+	etcdCli, exists := c.Get("EtcdServer")
+	if exists == false {
+		err = errors.New("Etcd client is empty")
+		return
+	}
+	cli := etcdCli.(*etcdv3.Etcd3Client)
+
+	ret, err := cli.Rollback(req.Key, req.Rev)
+	if err != nil {
+		return
+	}
+	go saveLog(c.Copy(), "回滚key", "key", req.Key,
+		"from_revision", ret.FromRevision, "to_revision", ret.ToRevision)
+	c.JSON(http.StatusOK, ret)
+}
+
+// 移动或重命名key
+func postEtcdKeyMove(c *gin.Context) {
+	doCopyEtcdKey(c, true)
+}
+
+// 复制key
+func postEtcdKeyCopy(c *gin.Context) {
+	doCopyEtcdKey(c, false)
+}
+
+// isMove 表示是否为移动,移动时复制完成后删除源key
+func doCopyEtcdKey(c *gin.Context, isMove bool) {
+	msg := "复制key"
+	if isMove {
+		msg = "移动key"
+	}
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw(msg+"错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	req := new(CopyReq)
+	err = c.Bind(req) //参数绑定
+	if err != nil {
+		return
+	}
+	if req.From == "" || req.To == "" {
+		err = errors.New("参数错误")
+		return
+	}
+	if req.From, err = scopedKey(c, req.From, false); err != nil {
+		return
+	}
+	if req.To, err = scopedKey(c, req.To, false); err != nil {
+		return
+	}
+	fromActions := []string{config.ACTION_READ}
+	if isMove {
+		fromActions = append(fromActions, config.ACTION_DELETE)
+	}
+	if !allowKeyActions(c, req.From, fromActions...) || !allowKey(c, config.ACTION_WRITE, req.To) {
+		forbidden(c)
+		return
+	}
+
+	etcdCli, exists := c.Get("EtcdServer")
+	if exists == false {
+		err = errors.New("Etcd client is empty")
+		return
+	}
+	cli := etcdCli.(*etcdv3.Etcd3Client)
+
+	opt := &etcdv3.CopyOption{
+		Conflict: req.Conflict,
+		DryRun:   req.DryRun,
+	}
+	var ret *etcdv3.CopyResult
+	if isMove {
+		ret, err = cli.Move(req.From, req.To, opt)
+	} else {
+		ret, err = cli.Copy(req.From, req.To, opt)
+	}
+	if err == etcdv3.ErrorKeyExists { // 返回冲突的key
+		c.JSON(http.StatusConflict, gin.H{
+			"msg":       err.Error(),
+			"conflicts": ret.Conflicts,
+		})
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	if !req.DryRun {
+		go saveLog(c.Copy(), msg, "from", req.From, "to", req.To, "conflict", req.Conflict)
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// 获取rev参数,未传时为0表示最新版本
+func getRevQuery(c *gin.Context) (int64, error) {
+	revStr := c.Query("rev")
+	if revStr == "" {
+		return 0, nil
+	}
+	rev, err := strconv.ParseInt(revStr, 10, 64)
+	if err != nil || rev < 0 {
+		return 0, errors.New("rev参数错误")
+	}
+	return rev, nil
+}
+
+// 获取类型提示参数,格式为 hint=key=type, key以/结尾时表示前缀
+func getTypeHints(c *gin.Context) map[string]string {
+	hints := make(map[string]string, 0)
+	for _, h := range c.QueryArray("hint") {
+		if i := strings.LastIndex(h, "="); i > 0 {
+			hints[h[:i]] = h[i+1:]
+		}
+	}
+	return hints
+}
+
+// 获取当前etcd服务配置的key前缀
+func getKeyPrefix(c *gin.Context) string {
+	if etcdCfg, exists := c.Get("EtcdServerCfg"); exists {
+		return etcdCfg.(*config.EtcdServer).KeyPrefix
+	}
+	return ""
+}
+
+// 保存日志, keysAndValues 为需要额外记录的字段
+func saveLog(c *gin.Context, msg string, keysAndValues ...interface{}) {
 	user := c.MustGet(gin.AuthUserKey).(string) // 用户名
 	userRole := ""                              // 角色信息
 	userRoleIn, exists := c.Get("userRole")
@@ -474,5 +889,5 @@ func saveLog(c *gin.Context, msg string) {
 		userRole = userRoleIn.(string)
 	}
 	//  日志
-	logger.Log.Infow(msg, "user", user, "role", userRole)
+	logger.Log.Infow(msg, append([]interface{}{"user", user, "role", userRole}, keysAndValues...)...)
 }
@@ -0,0 +1,130 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/qiuhoude/etcd-manage/program/etcdv3"
+	"github.com/qiuhoude/etcd-manage/program/logger"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 没有事件时发送心跳的间隔,防止代理断开空闲连接
+const watchHeartbeat = 15 * time.Second
+
+type connContextKey struct{}
+
+// ConnContext 把连接保存到请求的context中,长连接和下载接口通过它取消服务的写超时
+func ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, conn)
+}
+
+// 取消当前连接的写超时,下一个请求开始时服务会重新设置
+func clearWriteDeadline(c *gin.Context) {
+	if conn, ok := c.Request.Context().Value(connContextKey{}).(net.Conn); ok {
+		conn.SetWriteDeadline(time.Time{})
+	}
+}
+
+// 取消当前连接的读写超时,用于上传大文件等耗时的请求
+func clearDeadlines(c *gin.Context) {
+	if conn, ok := c.Request.Context().Value(connContextKey{}).(net.Conn); ok {
+		conn.SetDeadline(time.Time{})
+	}
+}
+
+// 通过 Server-Sent Events 推送key的变化
+// 每个事件的id为其版本号,浏览器断线重连时会通过 Last-Event-ID 从下一个版本继续推送
+func getEtcdWatch(c *gin.Context) {
+	go saveLog(c.Copy(), "监听key变化")
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Errorw("监听key变化错误", "err", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+	}()
+
+	etcdCli, exists := c.Get("EtcdServer")
+	if exists == false {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": "Etcd client is empty",
+		})
+		return
+	}
+	cli := etcdCli.(*etcdv3.Etcd3Client)
+
+	// 只能监听服务配置的key前缀之下
+	key, err := scopedKey(c, c.Query("key"), true)
+	if err != nil {
+		return
+	}
+
+	rev, err := getRevQuery(c)
+	if err != nil {
+		return
+	}
+	if lastID := c.GetHeader("Last-Event-ID"); lastID != "" {
+		lastRev, err := strconv.ParseInt(lastID, 10, 64)
+		if err == nil && lastRev > 0 {
+			rev = lastRev + 1
+		}
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	wch := cli.Watch(ctx, key, rev)
+
+	clearWriteDeadline(c)
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 关闭nginx缓冲
+	c.Status(http.StatusOK)
+
+	ticker := time.NewTicker(watchHeartbeat)
+	defer ticker.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case wresp, ok := <-wch:
+			if !ok {
+				return false
+			}
+			if wresp.CompactRevision != 0 { // 需要的版本已被压缩,由客户端决定如何重新加载
+				writeSSE(w, "", "compacted", gin.H{
+					"compact_revision": strconv.FormatInt(wresp.CompactRevision, 10),
+				})
+				return false
+			}
+			if werr := wresp.Err(); werr != nil {
+				writeSSE(w, "", "error", gin.H{"msg": werr.Error()})
+				return false
+			}
+			for _, ev := range wresp.Events {
+				e := etcdv3.NewWatchEvent(ev)
+				e.Key = displayKey(c, e.Key)
+				writeSSE(w, strconv.FormatInt(e.Revision, 10), "message", e)
+			}
+			return true
+		case <-ticker.C:
+			_, werr := io.WriteString(w, ": ping\n\n")
+			return werr == nil
+		}
+	})
+}
+
+// 写入一个SSE事件
+func writeSSE(w io.Writer, id, event string, data interface{}) {
+	body, _ := json.Marshal(data)
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, body)
+}
@@ -17,6 +17,31 @@ const lang = {
         addKey:'Add Key',
         open:'Open',
         show:'Show'
+    },
+    member:{
+        consistency:'Consistency Check',
+        consistent:'All members are consistent at revision {rev}',
+        inconsistent:'Members are not consistent at revision {rev}',
+        divergent:'Divergent',
+        transferLeader:'Transfer Leader',
+        transferConfirm:'Are you sure you want to transfer the leader to {name}?',
+        transferred:'The leader is now {name} ({leader})'
+    },
+    server:{
+        backup:'Backup',
+        restore:'Restore',
+        restoreTitle:'Restore backup to {name}',
+        restoreFile:'Backup file',
+        restoreFileEmpty:'Please select a backup file',
+        restorePrefix:'Target prefix',
+        restorePrefixTips:'Empty to restore to the prefix of the backup',
+        conflict:'On conflict',
+        conflictFail:'Abort',
+        conflictSkip:'Skip',
+        conflictOverwrite:'Overwrite',
+        dryRun:'Dry run, only show the summary',
+        restoreResult:'Total {total}, created {created}, updated {updated}, skipped {skipped}, unchanged {unchanged}, leases {leases}',
+        restoreConflicts:'These keys already exist with different values:'
     }
 }
 
@@ -17,6 +17,31 @@ const lang = {
         editKey:'编辑key',
         open:'打开',
         show:'查看'
+    },
+    member:{
+        consistency:'一致性检查',
+        consistent:'所有节点在版本 {rev} 的数据一致',
+        inconsistent:'节点在版本 {rev} 的数据不一致',
+        divergent:'不一致',
+        transferLeader:'转移leader',
+        transferConfirm:'确定将leader转移到 {name}？',
+        transferred:'当前leader为 {name} ({leader})'
+    },
+    server:{
+        backup:'备份',
+        restore:'恢复',
+        restoreTitle:'恢复备份到 {name}',
+        restoreFile:'备份文件',
+        restoreFileEmpty:'请选择备份文件',
+        restorePrefix:'目标前缀',
+        restorePrefixTips:'为空时恢复到备份时的前缀',
+        conflict:'冲突处理',
+        conflictFail:'放弃',
+        conflictSkip:'跳过',
+        conflictOverwrite:'覆盖',
+        dryRun:'预演,只显示汇总',
+        restoreResult:'共 {total} 个, 新建 {created}, 覆盖 {updated}, 跳过 {skipped}, 未变化 {unchanged}, 租约 {leases}',
+        restoreConflicts:'以下key已存在且值不同:'
     }
 }
 